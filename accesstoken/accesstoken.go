@@ -0,0 +1,265 @@
+// Package accesstoken provides personal access tokens for API clients.
+//
+// Users create named tokens with scopes, and API clients send them in
+// the Authorization header as bearer tokens:
+//
+// 	Authorization: Bearer <token>
+//
+// Only hashes of tokens are stored, so a token can't be recovered
+// after it's created. Service.Authenticate validates tokens of requests,
+// and Service reports the user of a validated token as authenticated.
+package accesstoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/users"
+)
+
+// Scope is a scope of access granted to a token.
+type Scope string
+
+const (
+	// Read allows reading, via GET and HEAD requests.
+	Read Scope = "read"
+	// Write allows making changes, via all other requests.
+	// It implies Read.
+	Write Scope = "write"
+)
+
+// Token is a personal access token.
+type Token struct {
+	ID        string         // ID of the token, which identifies it publicly.
+	Name      string         // Name given to the token by its user.
+	User      users.UserSpec // User the token belongs to.
+	Scopes    []Scope
+	CreatedAt time.Time
+
+	// Hash is the hash of the token, as returned by Hash.
+	// The token itself isn't stored anywhere.
+	Hash string `json:"-"`
+}
+
+// HasScope reports whether t was granted scope.
+func (t Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || (s == Write && scope == Read) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns the hash of token, for looking it up in a Store.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Service manages personal access tokens of users in a Store.
+//
+// It also implements users.Service on top of another users.Service.
+// The user of the token validated by Authenticate is the authenticated user,
+// and otherwise the other users service is used.
+type Service struct {
+	store Store
+	users users.Service
+}
+
+// NewService returns a service that stores tokens in store,
+// for users of the users service.
+func NewService(store Store, users users.Service) *Service {
+	return &Service{store: store, users: users}
+}
+
+// Create creates a token named name with scopes for the authenticated user.
+// It returns the token, which is only available at this time, and its details.
+func (s *Service) Create(ctx context.Context, name string, scopes []Scope) (token string, _ Token, _ error) {
+	user, err := s.manager(ctx)
+	if err != nil {
+		return "", Token{}, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", Token{}, errors.New("token name must not be empty")
+	}
+	if len(scopes) == 0 {
+		return "", Token{}, errors.New("token must have at least one scope")
+	}
+	for _, scope := range scopes {
+		if scope != Read && scope != Write {
+			return "", Token{}, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", Token{}, err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	hash := Hash(token)
+	t := Token{
+		ID:        hash[:16],
+		Name:      name,
+		User:      user,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+		Hash:      hash,
+	}
+	err = s.store.Create(ctx, t)
+	if err != nil {
+		return "", Token{}, err
+	}
+	return token, t, nil
+}
+
+// List lists tokens of the authenticated user.
+func (s *Service) List(ctx context.Context) ([]Token, error) {
+	user, err := s.manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.List(ctx, user)
+}
+
+// Revoke revokes the token with the specified ID of the authenticated user.
+func (s *Service) Revoke(ctx context.Context, id string) error {
+	user, err := s.manager(ctx)
+	if err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, user, id)
+}
+
+// manager returns the authenticated user, if they're allowed to manage tokens.
+// Tokens can't be used to manage tokens, so that a token
+// can't be used to create another one with more scopes.
+func (s *Service) manager(ctx context.Context) (users.UserSpec, error) {
+	if _, ok := ctx.Value(tokenContextKey).(Token); ok {
+		return users.UserSpec{}, os.ErrPermission
+	}
+	user, err := s.users.GetAuthenticatedSpec(ctx)
+	if err != nil {
+		return users.UserSpec{}, err
+	}
+	if user.ID == 0 {
+		return users.UserSpec{}, os.ErrPermission
+	}
+	return user, nil
+}
+
+// Authenticate returns a handler that validates the bearer token of requests,
+// if any, and serves them with h. The token and its user are set in the
+// request context, and can be retrieved with FromContext. Requests with
+// an invalid token, or one without the scope they need, are rejected.
+func (s *Service) Authenticate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		if auth == "" {
+			h.ServeHTTP(w, req)
+			return
+		}
+		const prefix = "Bearer "
+		if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+			unauthorized(w, "invalid_request")
+			return
+		}
+		t, err := s.store.Lookup(req.Context(), Hash(auth[len(prefix):]))
+		if os.IsNotExist(err) {
+			unauthorized(w, "invalid_token")
+			return
+		} else if err != nil {
+			log.Println("accesstoken: looking up token:", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		scope := Write
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			scope = Read
+		}
+		if !t.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+		user, err := s.users.Get(req.Context(), t.User)
+		if err != nil {
+			// The user of the token no longer exists.
+			unauthorized(w, "invalid_token")
+			return
+		}
+		ctx := context.WithValue(req.Context(), tokenContextKey, t)
+		ctx = context.WithValue(ctx, userContextKey, user)
+		h.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func unauthorized(w http.ResponseWriter, error string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s"`, error))
+	http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+}
+
+// FromContext returns the token validated by Service.Authenticate
+// and its user, if any.
+func FromContext(ctx context.Context) (Token, users.User, bool) {
+	t, ok := ctx.Value(tokenContextKey).(Token)
+	if !ok {
+		return Token{}, users.User{}, false
+	}
+	user, _ := ctx.Value(userContextKey).(users.User)
+	return t, user, true
+}
+
+func (s *Service) Get(ctx context.Context, user users.UserSpec) (users.User, error) {
+	return s.users.Get(ctx, user)
+}
+
+func (s *Service) GetAuthenticatedSpec(ctx context.Context) (users.UserSpec, error) {
+	if user, ok := ctx.Value(userContextKey).(users.User); ok {
+		return user.UserSpec, nil
+	}
+	return s.users.GetAuthenticatedSpec(ctx)
+}
+
+func (s *Service) GetAuthenticated(ctx context.Context) (users.User, error) {
+	if user, ok := ctx.Value(userContextKey).(users.User); ok {
+		return user, nil
+	}
+	return s.users.GetAuthenticated(ctx)
+}
+
+func (s *Service) Edit(ctx context.Context, er users.EditRequest) (users.User, error) {
+	if _, ok := ctx.Value(tokenContextKey).(Token); ok {
+		return users.User{}, os.ErrPermission
+	}
+	return s.users.Edit(ctx, er)
+}
+
+// contextKey is a value for use with context.WithValue. It's used as
+// a pointer so it fits in an interface{} without allocation.
+type contextKey struct {
+	name string
+}
+
+func (k *contextKey) String() string { return "github.com/shurcooL/issuesapp/accesstoken context value " + k.name }
+
+var (
+	// tokenContextKey is a context key for the validated token.
+	// The associated value will be of type Token.
+	tokenContextKey = &contextKey{"Token"}
+
+	// userContextKey is a context key for the user of the validated token.
+	// The associated value will be of type users.User.
+	userContextKey = &contextKey{"User"}
+)
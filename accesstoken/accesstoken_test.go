@@ -0,0 +1,148 @@
+package accesstoken_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/issuesapp/accesstoken"
+	"github.com/shurcooL/users"
+)
+
+func TestAuthenticate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesstoken_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := accesstoken.NewFileStore(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gopher := users.User{UserSpec: users.UserSpec{ID: 1, Domain: "example.org"}, Login: "gopher"}
+	s := accesstoken.NewService(store, mockUsers{gopher})
+
+	signedIn := context.WithValue(context.Background(), signedInKey, gopher.UserSpec)
+	if _, _, err := s.Create(context.Background(), "ci", []accesstoken.Scope{accesstoken.Read}); !os.IsPermission(err) {
+		t.Errorf("Create without a signed in user: got error %v, want permission error", err)
+	}
+	readToken, _, err := s.Create(signedIn, "ci", []accesstoken.Scope{accesstoken.Read})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeToken, writeT, err := s.Create(signedIn, "bot", []accesstoken.Scope{accesstoken.Write})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := s.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, err := s.GetAuthenticatedSpec(req.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		fmt.Fprint(w, user.ID)
+	}))
+	do := func(method, token string) (code int, body string) {
+		req := httptest.NewRequest(method, "/api/issues/list", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+
+	tests := []struct {
+		method   string
+		token    string
+		wantCode int
+		wantUser string
+	}{
+		{"GET", "", http.StatusOK, "0"},
+		{"GET", readToken, http.StatusOK, "1"},
+		{"POST", readToken, http.StatusForbidden, ""},
+		{"GET", writeToken, http.StatusOK, "1"},
+		{"POST", writeToken, http.StatusOK, "1"},
+		{"GET", "bogus", http.StatusUnauthorized, ""},
+	}
+	for _, tc := range tests {
+		code, body := do(tc.method, tc.token)
+		if code != tc.wantCode {
+			t.Errorf("%s with token %q: got code %v, want %v", tc.method, tc.token, code, tc.wantCode)
+		}
+		if code == http.StatusOK && body != tc.wantUser {
+			t.Errorf("%s with token %q: got user %q, want %q", tc.method, tc.token, body, tc.wantUser)
+		}
+	}
+
+	// Tokens are stored hashed, and persist in the file.
+	b, err := ioutil.ReadFile(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), writeToken) {
+		t.Error("tokens file contains a token, want only its hash")
+	}
+	store, err = accesstoken.NewFileStore(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = accesstoken.NewService(store, mockUsers{gopher})
+	ts, err := s.List(signedIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 2 || ts[0].Name != "ci" || ts[1].Name != "bot" {
+		t.Fatalf("got tokens %+v, want ci and bot", ts)
+	}
+
+	err = s.Revoke(signedIn, writeT.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = s.Authenticate(http.NotFoundHandler())
+	if code, _ := do("GET", writeToken); code != http.StatusUnauthorized {
+		t.Errorf("GET with revoked token: got code %v, want %v", code, http.StatusUnauthorized)
+	}
+}
+
+type contextKey struct{}
+
+// signedInKey is a context key for the signed in user of mockUsers.
+var signedInKey contextKey
+
+// mockUsers is a users service with a single user, who's signed in
+// when the context has signedInKey set.
+type mockUsers struct {
+	user users.User
+}
+
+func (s mockUsers) Get(_ context.Context, user users.UserSpec) (users.User, error) {
+	if user != s.user.UserSpec {
+		return users.User{}, fmt.Errorf("user %v not found", user)
+	}
+	return s.user, nil
+}
+
+func (s mockUsers) GetAuthenticatedSpec(ctx context.Context) (users.UserSpec, error) {
+	user, _ := ctx.Value(signedInKey).(users.UserSpec)
+	return user, nil
+}
+
+func (s mockUsers) GetAuthenticated(ctx context.Context) (users.User, error) {
+	user, _ := s.GetAuthenticatedSpec(ctx)
+	if user.ID == 0 {
+		return users.User{}, nil
+	}
+	return s.Get(ctx, user)
+}
+
+func (mockUsers) Edit(context.Context, users.EditRequest) (users.User, error) {
+	return users.User{}, os.ErrPermission
+}
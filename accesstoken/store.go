@@ -0,0 +1,173 @@
+package accesstoken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/shurcooL/users"
+)
+
+// Store stores tokens by their hashes.
+type Store interface {
+	// Create stores the new token t.
+	Create(ctx context.Context, t Token) error
+
+	// Lookup returns the token with the specified hash.
+	// If there isn't one, an error satisfying os.IsNotExist is returned.
+	Lookup(ctx context.Context, hash string) (Token, error)
+
+	// List lists the tokens of user, oldest first.
+	List(ctx context.Context, user users.UserSpec) ([]Token, error)
+
+	// Delete deletes the token of user with the specified ID.
+	// If there isn't one, an error satisfying os.IsNotExist is returned.
+	Delete(ctx context.Context, user users.UserSpec, id string) error
+}
+
+// NewMemoryStore returns a Store that keeps tokens in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{tokens: make(map[string]Token)}
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]Token // Keyed by hash.
+}
+
+func (s *memoryStore) Create(_ context.Context, t Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[t.Hash]; ok {
+		return fmt.Errorf("token %s already exists", t.ID)
+	}
+	s.tokens[t.Hash] = t
+	return nil
+}
+
+func (s *memoryStore) Lookup(_ context.Context, hash string) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[hash]
+	if !ok {
+		return Token{}, os.ErrNotExist
+	}
+	return t, nil
+}
+
+func (s *memoryStore) List(_ context.Context, user users.UserSpec) ([]Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ts []Token
+	for _, t := range s.tokens {
+		if t.User == user {
+			ts = append(ts, t)
+		}
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i].CreatedAt.Before(ts[j].CreatedAt) })
+	return ts, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, user users.UserSpec, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, t := range s.tokens {
+		if t.User == user && t.ID == id {
+			delete(s.tokens, hash)
+			return nil
+		}
+	}
+	return os.ErrNotExist
+}
+
+// NewFileStore returns a Store that keeps tokens in a JSON file at path.
+// Tokens are read from the file if it exists, and the file is
+// rewritten after every change.
+func NewFileStore(path string) (Store, error) {
+	s := &fileStore{path: path, memoryStore: memoryStore{tokens: make(map[string]Token)}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var ts []storedToken
+	err = json.Unmarshal(b, &ts)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for _, t := range ts {
+		t.Token.Hash = t.Hash
+		s.tokens[t.Hash] = t.Token
+	}
+	return s, nil
+}
+
+type fileStore struct {
+	path   string
+	saveMu sync.Mutex // Held during changes, so that they're saved in order.
+	memoryStore
+}
+
+// storedToken is a token as stored in a file, including its hash.
+type storedToken struct {
+	Token
+	Hash string
+}
+
+func (s *fileStore) Create(ctx context.Context, t Token) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	err := s.memoryStore.Create(ctx, t)
+	if err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *fileStore) Delete(ctx context.Context, user users.UserSpec, id string) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	err := s.memoryStore.Delete(ctx, user, id)
+	if err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// save writes all tokens to the file.
+func (s *fileStore) save() error {
+	s.mu.Lock()
+	ts := make([]storedToken, 0, len(s.tokens))
+	for hash, t := range s.tokens {
+		ts = append(ts, storedToken{Token: t, Hash: hash})
+	}
+	s.mu.Unlock()
+	sort.Slice(ts, func(i, j int) bool { return ts[i].CreatedAt.Before(ts[j].CreatedAt) })
+	b, err := json.MarshalIndent(ts, "", "\t")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that the file
+	// is never left partially written.
+	f, err := ioutil.TempFile(filepath.Dir(s.path), ".tokens-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -13,14 +13,12 @@ import (
 	"strings"
 
 	"github.com/shurcooL/home/httputil"
-	"github.com/shurcooL/httpgzip"
 	"github.com/shurcooL/issues"
 	"github.com/shurcooL/issues/fs"
 	"github.com/shurcooL/issuesapp"
 	"github.com/shurcooL/issuesapp/httphandler"
 	"github.com/shurcooL/issuesapp/httproute"
 	"github.com/shurcooL/reactions"
-	"github.com/shurcooL/reactions/emojis"
 	"github.com/shurcooL/users"
 	"github.com/shurcooL/webdavfs/vfsutil"
 	"golang.org/x/net/webdav"
@@ -133,9 +131,6 @@ func run() error {
 		fmt.Fprintln(w, "Sorry, this is a read-only instance and it doesn't support signing in.")
 	})
 
-	emojisHandler := httpgzip.FileServer(emojis.Assets, httpgzip.FileServerOptions{ServeError: httpgzip.Detailed})
-	http.Handle("/emojis/", http.StripPrefix("/emojis", emojisHandler))
-
 	log.Println("Started.")
 
 	err = http.ListenAndServe(*httpFlag, nil)
@@ -0,0 +1,193 @@
+package issuesapp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/users"
+)
+
+// Labeler is an optional interface that an issues.Service can implement
+// to support adding and removing labels of issues in bulk triage actions.
+type Labeler interface {
+	// AddLabel adds the label with name to issue id.
+	// It does nothing if the issue already has it.
+	AddLabel(ctx context.Context, repo issues.RepoSpec, id uint64, name string) error
+
+	// RemoveLabel removes the label with name from issue id.
+	// It does nothing if the issue doesn't have it.
+	RemoveLabel(ctx context.Context, repo issues.RepoSpec, id uint64, name string) error
+}
+
+// Milestoner is an optional interface that an issues.Service can implement
+// to support setting the milestone of issues in bulk triage actions.
+type Milestoner interface {
+	// SetMilestone sets the milestone of issue id to the one with name.
+	// An empty name removes the issue from its milestone.
+	SetMilestone(ctx context.Context, repo issues.RepoSpec, id uint64, name string) error
+}
+
+// Bulk triage actions, as specified by the "action" form value.
+const (
+	bulkClose        = "close"
+	bulkReopen       = "reopen"
+	bulkAddLabel     = "add-label"
+	bulkRemoveLabel  = "remove-label"
+	bulkSetMilestone = "set-milestone"
+)
+
+const (
+	// maxBulkIssues is the maximum number of issues a bulk action can be applied to.
+	maxBulkIssues = 100
+
+	// bulkConcurrency is the number of issues a bulk action is applied to at once.
+	bulkConcurrency = 8
+)
+
+// bulkResult is the result of applying a bulk action to an issue.
+type bulkResult struct {
+	ID    uint64
+	Title string // Title of the issue, if it could be fetched.
+	Error string // Error is empty if the action succeeded.
+}
+
+// PostBulkHandler applies a bulk triage action to the issues selected
+// by the "issue" form values, and renders the result for each issue.
+// Users need to be able to edit an issue and change its state to triage it,
+// and the policy decides which labels and milestones they can set.
+func (h *handler) PostBulkHandler(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != http.MethodPost {
+		return httperror.Method{Allowed: []string{http.MethodPost}}
+	}
+	if err := req.ParseForm(); err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("req.ParseForm: %v", err)}
+	}
+	var ids []uint64
+	for _, v := range req.PostForm["issue"] {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return httperror.BadRequest{Err: fmt.Errorf("invalid issue ID %q: %v", v, err)}
+		}
+		ids = append(ids, id)
+	}
+	switch {
+	case len(ids) == 0:
+		return httperror.BadRequest{Err: fmt.Errorf("no issues selected")}
+	case len(ids) > maxBulkIssues:
+		return httperror.BadRequest{Err: fmt.Errorf("can't apply a bulk action to more than %d issues", maxBulkIssues)}
+	}
+	action, value := req.PostForm.Get("action"), req.PostForm.Get("label")
+	switch action {
+	case bulkClose, bulkReopen:
+	case bulkAddLabel, bulkRemoveLabel:
+		if _, ok := h.is.(Labeler); !ok {
+			return httperror.BadRequest{Err: fmt.Errorf("issues service doesn't implement Labeler")}
+		}
+		if value == "" {
+			return httperror.BadRequest{Err: fmt.Errorf("no label specified")}
+		}
+	case bulkSetMilestone:
+		if _, ok := h.is.(Milestoner); !ok {
+			return httperror.BadRequest{Err: fmt.Errorf("issues service doesn't implement Milestoner")}
+		}
+		value = req.PostForm.Get("milestone")
+	default:
+		return httperror.BadRequest{Err: fmt.Errorf("unsupported bulk action %q", action)}
+	}
+
+	st, err := h.state(req, 0)
+	if err != nil {
+		return err
+	}
+	results := make([]bulkResult, len(ids))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, bulkConcurrency)
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id uint64) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			issue, err := h.bulkEdit(req.Context(), st.RepoSpec, st.CurrentUser, id, action, value)
+			results[i] = bulkResult{ID: id, Title: issue.Title}
+			if err != nil {
+				log.Printf("PostBulkHandler: %s issue %d of %q: %v\n", action, id, st.RepoSpec.URI, err)
+				results[i].Error = bulkErrorText(err, st.CurrentUser)
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = h.static.ExecuteTemplate(w, "bulk.html.tmpl", struct {
+		state
+		Results []bulkResult
+	}{st, results})
+	if err != nil {
+		return fmt.Errorf("h.static.ExecuteTemplate: %v", err)
+	}
+	return nil
+}
+
+// bulkEdit applies a bulk action to issue id, on behalf of user.
+// The returned issue is the zero value if it couldn't be fetched.
+func (h *handler) bulkEdit(ctx context.Context, repo issues.RepoSpec, user users.User, id uint64, action, value string) (issues.Issue, error) {
+	issue, err := h.is.Get(ctx, repo, id)
+	if err != nil {
+		return issues.Issue{}, err
+	}
+	// The issues service reports whether user can edit the issue, as on its page.
+	if !issue.Editable || !h.policy().CanChangeState(ctx, repo, issue, user) {
+		return issue, os.ErrPermission
+	}
+	switch action {
+	case bulkClose, bulkReopen:
+		s := issues.OpenState
+		if action == bulkClose {
+			s = issues.ClosedState
+		}
+		if issue.State == s {
+			return issue, nil
+		}
+		_, _, err = h.is.Edit(ctx, repo, id, issues.IssueRequest{State: &s})
+	case bulkAddLabel:
+		if !h.policy().CanApplyLabel(ctx, repo, value, user) {
+			return issue, os.ErrPermission
+		}
+		err = h.is.(Labeler).AddLabel(ctx, repo, id, value)
+	case bulkRemoveLabel:
+		// Users who can't apply a label can't remove it either.
+		if !h.policy().CanApplyLabel(ctx, repo, value, user) {
+			return issue, os.ErrPermission
+		}
+		err = h.is.(Labeler).RemoveLabel(ctx, repo, id, value)
+	case bulkSetMilestone:
+		if !h.policy().CanSetMilestone(ctx, repo, issue, user) {
+			return issue, os.ErrPermission
+		}
+		err = h.is.(Milestoner).SetMilestone(ctx, repo, id, value)
+	}
+	return issue, err
+}
+
+// bulkErrorText returns the text to show user for err of a bulk action.
+// Details of internal errors are only shown to site admins.
+func bulkErrorText(err error, user users.User) string {
+	switch {
+	case os.IsNotExist(err):
+		return "Issue not found."
+	case os.IsPermission(err):
+		return "You don't have permission to triage this issue."
+	case user.SiteAdmin:
+		return err.Error()
+	default:
+		return "Internal error."
+	}
+}
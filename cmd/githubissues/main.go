@@ -19,7 +19,6 @@ import (
 	"github.com/shurcooL/githubv4"
 	"github.com/shurcooL/home/httputil"
 	"github.com/shurcooL/httpgzip"
-	"github.com/shurcooL/issues"
 	"github.com/shurcooL/issuesapp"
 	"github.com/shurcooL/issuesapp/httphandler"
 	"github.com/shurcooL/issuesapp/httproute"
@@ -70,6 +69,7 @@ func main() {
 
 	issuesOpt := issuesapp.Options{
 		Notifications: notificationsService,
+		EmojisURL:     "/emojis", // Share the emojis served for both apps below.
 
 		HeadPre: `<style type="text/css">
 	body {
@@ -107,29 +107,8 @@ func main() {
 	}
 	notificationsApp := notificationsapp.New(notificationsService, usersService, notificationsOpt)
 
-	githubHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		vars := mux.Vars(req)
-		prefixLen := 20 + len(vars["owner"]) + len(vars["repo"]) // len("/github.com/{owner}/{repo}/issues") with substitutions.
-		if prefix := req.URL.Path[:prefixLen]; req.URL.Path == prefix+"/" {
-			baseURL := prefix
-			if req.URL.RawQuery != "" {
-				baseURL += "?" + req.URL.RawQuery
-			}
-			http.Redirect(w, req, baseURL, http.StatusFound)
-			return
-		}
-		req.URL.Path = req.URL.Path[prefixLen:]
-		if req.URL.Path == "" {
-			req.URL.Path = "/"
-		}
-		req = req.WithContext(context.WithValue(req.Context(),
-			issuesapp.RepoSpecContextKey, issues.RepoSpec{URI: "github.com/" + vars["owner"] + "/" + vars["repo"]}))
-		req = req.WithContext(context.WithValue(req.Context(),
-			issuesapp.BaseURIContextKey, fmt.Sprintf("/github.com/%s/%s/issues", vars["owner"], vars["repo"])))
-		issuesApp.ServeHTTP(w, req)
-	})
-	r.Path("/github.com/{owner}/{repo}/issues").Handler(githubHandler)
-	r.PathPrefix("/github.com/{owner}/{repo}/issues/").Handler(githubHandler)
+	issuesRouter := issuesapp.NewRouter(issuesApp, issuesapp.PatternResolver("/github.com/{owner}/{repo}/issues", nil))
+	r.PathPrefix("/github.com/{owner}/{repo}/issues").Handler(issuesRouter)
 
 	notificationsHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		prefixLen := len("/notifications")
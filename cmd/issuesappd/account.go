@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/issuesapp/localusers"
+	"github.com/shurcooL/users"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// sessionKeyLen is the length of generated session keys, in bytes.
+const sessionKeyLen = 32
+
+// loadSessionKey loads the session key in file at path.
+// If the file doesn't exist, a new random key is generated and written to it.
+func loadSessionKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if !os.IsNotExist(err) {
+		return key, err
+	}
+	key = make([]byte, sessionKeyLen)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(path, key, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// hashPassword reads a password from stdin, and prints its hash to stdout.
+func hashPassword() error {
+	fmt.Fprintln(os.Stderr, "Enter password:")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("reading password: %v", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return fmt.Errorf("empty password")
+	}
+	hash, err := localusers.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+// userBar is a component that shows the signed in user and a button to sign out,
+// or nothing if no user is signed in.
+type userBar struct {
+	User      users.User
+	ReturnURL string // URL to return to after signing out.
+}
+
+func (u userBar) Render() []*html.Node {
+	// <div style="text-align: right; margin-bottom: 20px;">
+	// 	<form method="post" action="/logout?return={{.ReturnURL}}">
+	// 		Signed in as <strong>{{.User.Login}}</strong>.
+	// 		<input class="btn" type="submit" value="Sign out">
+	// 	</form>
+	// </div>
+	if u.User.ID == 0 {
+		return nil
+	}
+	form := &html.Node{
+		Type: html.ElementNode, Data: atom.Form.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Method.String(), Val: "post"},
+			{Key: atom.Action.String(), Val: "/logout?" + url.Values{"return": {u.ReturnURL}}.Encode()},
+		},
+	}
+	form.AppendChild(htmlg.Text("Signed in as "))
+	form.AppendChild(htmlg.Strong(u.User.Login))
+	form.AppendChild(htmlg.Text(". "))
+	form.AppendChild(&html.Node{
+		Type: html.ElementNode, Data: atom.Input.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "btn"},
+			{Key: atom.Type.String(), Val: "submit"},
+			{Key: atom.Value.String(), Val: "Sign out"},
+		},
+	})
+	div := &html.Node{
+		Type: html.ElementNode, Data: atom.Div.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Style.String(), Val: "text-align: right; margin-bottom: 20px;"},
+		},
+	}
+	div.AppendChild(form)
+	return []*html.Node{div}
+}
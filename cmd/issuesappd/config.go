@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/shurcooL/issuesapp/policy"
+	"github.com/shurcooL/users"
+	"gopkg.in/yaml.v2"
+)
+
+// config is the configuration of issuesappd.
+type config struct {
+	Listen string `yaml:"listen"` // Address to listen on for HTTP connections. Default is ":8080".
+	TLS    struct {
+		CertFile string `yaml:"cert_file"`
+		KeyFile  string `yaml:"key_file"`
+	} `yaml:"tls"` // If set, HTTPS connections are served instead.
+
+	DataDir   string `yaml:"data_dir"`   // Directory where issues are stored.
+	UploadDir string `yaml:"upload_dir"` // Directory where uploaded images are stored. If empty, uploads are disabled.
+	Dashboard string `yaml:"dashboard"`  // Base path of a dashboard of issues of all repositories. Optional.
+
+	// IssueTemplatesDir is a directory with issue templates of repositories,
+	// in subdirectories named by their URIs. Optional.
+	IssueTemplatesDir string `yaml:"issue_templates_dir"`
+
+	Repos []repoConfig `yaml:"repos"`
+
+	// DefaultRole is the role of signed in users in repositories where they
+	// don't have one: "none", "reader", "triager" or "maintainer". Default is "reader".
+	DefaultRole string `yaml:"default_role"`
+
+	Domain         string `yaml:"domain"`           // Domain of user accounts.
+	UsersFile      string `yaml:"users_file"`       // JSON file with user accounts, as read by localusers.NewService.
+	SessionKeyFile string `yaml:"session_key_file"` // File with the key for signing session cookies. It's generated if it doesn't exist.
+	TokensFile     string `yaml:"tokens_file"`      // File where personal access tokens are stored. Optional.
+
+	PinsFile  string `yaml:"pins_file"`  // File where pinned issues are stored. Optional.
+	MaxPinned int    `yaml:"max_pinned"` // Maximum number of pinned issues per repository. Default is issuesapp.DefaultMaxPinned.
+
+	LocksFile string `yaml:"locks_file"` // File where locked conversations are stored. Optional.
+}
+
+// repoConfig is the configuration of a repository.
+type repoConfig struct {
+	URI      string `yaml:"uri"`       // Repository URI, e.g., "example.org/project".
+	BasePath string `yaml:"base_path"` // Base path of its issues, e.g., "/project/issues".
+
+	Roles map[uint64]string `yaml:"roles"` // Roles of users in the repository, keyed by user ID.
+
+	// AllowedLabels are labels that users who aren't maintainers can apply.
+	AllowedLabels []string `yaml:"allowed_labels"`
+}
+
+// reservedPaths are paths served by issuesappd itself,
+// which can't be used by repositories or the dashboard.
+var reservedPaths = []string{"/api", "/usercontent", "/login", "/logout"}
+
+// loadConfig loads and validates the YAML configuration file at path.
+func loadConfig(path string) (config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config{}, err
+	}
+	var c config
+	err = yaml.UnmarshalStrict(b, &c)
+	if err != nil {
+		return config{}, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if c.Listen == "" {
+		c.Listen = ":8080"
+	}
+	if c.DefaultRole == "" {
+		c.DefaultRole = policy.Reader.String()
+	}
+	err = c.validate()
+	if err != nil {
+		return config{}, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return c, nil
+}
+
+func (c config) validate() error {
+	if c.DataDir == "" {
+		return fmt.Errorf("data_dir is required")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls needs both cert_file and key_file")
+	}
+	if len(c.Repos) == 0 {
+		return fmt.Errorf("no repos")
+	}
+	basePaths := make(map[string]bool)
+	if c.Dashboard != "" && c.Dashboard != "/" {
+		if err := validateBasePath(c.Dashboard); err != nil {
+			return fmt.Errorf("dashboard: %v", err)
+		}
+		basePaths[c.Dashboard] = true
+	}
+	uris := make(map[string]bool)
+	for _, r := range c.Repos {
+		if r.URI == "" {
+			return fmt.Errorf("repo with base_path %q has no uri", r.BasePath)
+		}
+		if uris[r.URI] {
+			return fmt.Errorf("repo %q is listed more than once", r.URI)
+		}
+		uris[r.URI] = true
+		if err := validateBasePath(r.BasePath); err != nil {
+			return fmt.Errorf("repo %q: %v", r.URI, err)
+		}
+		if basePaths[r.BasePath] {
+			return fmt.Errorf("repo %q: base_path %q is used more than once", r.URI, r.BasePath)
+		}
+		basePaths[r.BasePath] = true
+		for id, role := range r.Roles {
+			if _, err := parseRole(role); err != nil {
+				return fmt.Errorf("repo %q: user %d: %v", r.URI, id, err)
+			}
+		}
+	}
+	if _, err := parseRole(c.DefaultRole); err != nil {
+		return fmt.Errorf("default_role: %v", err)
+	}
+	if c.MaxPinned < 0 {
+		return fmt.Errorf("max_pinned can't be negative")
+	}
+	switch {
+	case c.UsersFile == "":
+		return fmt.Errorf("users_file is required")
+	case c.SessionKeyFile == "":
+		return fmt.Errorf("session_key_file is required")
+	case c.Domain == "":
+		return fmt.Errorf("domain is required")
+	}
+	return nil
+}
+
+// policy returns the policy with roles of users in repositories.
+// c must be valid.
+func (c config) policy() policy.Roles {
+	p := policy.Roles{
+		Repos:         make(map[string]map[users.UserSpec]policy.Role),
+		AllowedLabels: make(map[string][]string),
+	}
+	p.Default, _ = parseRole(c.DefaultRole)
+	for _, r := range c.Repos {
+		roles := make(map[users.UserSpec]policy.Role)
+		for id, role := range r.Roles {
+			roles[users.UserSpec{ID: id, Domain: c.Domain}], _ = parseRole(role)
+		}
+		p.Repos[r.URI] = roles
+		p.AllowedLabels[r.URI] = r.AllowedLabels
+	}
+	return p
+}
+
+// parseRole parses a role by its name.
+func parseRole(name string) (policy.Role, error) {
+	for _, role := range []policy.Role{policy.None, policy.Reader, policy.Triager, policy.Maintainer} {
+		if name == role.String() {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q", name)
+}
+
+// validateBasePath returns an error if p isn't a valid base path,
+// like "/project/issues".
+func validateBasePath(p string) error {
+	switch {
+	case !strings.HasPrefix(p, "/"):
+		return fmt.Errorf("base path %q doesn't begin with a slash", p)
+	case strings.HasSuffix(p, "/"):
+		return fmt.Errorf("base path %q ends with a slash", p)
+	}
+	for _, r := range reservedPaths {
+		if p == r || strings.HasPrefix(p, r+"/") {
+			return fmt.Errorf("base path %q is reserved", p)
+		}
+	}
+	return nil
+}
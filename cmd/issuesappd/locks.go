@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/users"
+)
+
+// lockEventIDBase is added to IDs of lock events,
+// so that they don't collide with IDs of events of the issues service.
+const lockEventIDBase = 1 << 32
+
+// lockStore is an issuesapp.LockStore that stores locked conversations
+// of all repositories and their lock events in a JSON file. If path is empty,
+// they're only kept in memory.
+type lockStore struct {
+	path  string
+	users users.Service // Used to get the actor of lock events.
+
+	mu    sync.Mutex
+	locks locks
+}
+
+// locks are locked conversations and lock events, keyed by repository URI and issue ID.
+type locks struct {
+	Locked map[string]map[uint64]bool
+	Events map[string]map[uint64][]issues.Event
+}
+
+// newLockStore returns a lock store that uses the file at path, if it's not empty.
+// The file is created when a conversation is first locked.
+func newLockStore(path string, users users.Service) (*lockStore, error) {
+	s := &lockStore{path: path, users: users}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		} else if err == nil {
+			err = json.Unmarshal(b, &s.locks)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.locks.Locked == nil {
+		s.locks.Locked = make(map[string]map[uint64]bool)
+	}
+	if s.locks.Events == nil {
+		s.locks.Events = make(map[string]map[uint64][]issues.Event)
+	}
+	return s, nil
+}
+
+func (s *lockStore) IsLocked(_ context.Context, repo issues.RepoSpec, id uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locks.Locked[repo.URI][id], nil
+}
+
+func (s *lockStore) SetLocked(ctx context.Context, repo issues.RepoSpec, id uint64, locked bool) (issues.Event, error) {
+	actor, err := s.users.GetAuthenticated(ctx)
+	if err != nil {
+		return issues.Event{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks.Locked[repo.URI][id] == locked {
+		// Nothing changes, so there's no event to record.
+		return issues.Event{}, nil
+	}
+	if s.locks.Locked[repo.URI] == nil {
+		s.locks.Locked[repo.URI] = make(map[uint64]bool)
+	}
+	if s.locks.Events[repo.URI] == nil {
+		s.locks.Events[repo.URI] = make(map[uint64][]issues.Event)
+	}
+	switch locked {
+	case true:
+		s.locks.Locked[repo.URI][id] = true
+	case false:
+		delete(s.locks.Locked[repo.URI], id)
+	}
+	e := issues.Event{
+		ID:        lockEventIDBase + uint64(len(s.locks.Events[repo.URI][id])),
+		Actor:     actor,
+		CreatedAt: time.Now().UTC(),
+		Type:      common.Unlocked,
+	}
+	if locked {
+		e.Type = common.Locked
+	}
+	s.locks.Events[repo.URI][id] = append(s.locks.Events[repo.URI][id], e)
+	return e, s.save()
+}
+
+func (s *lockStore) ListLockEvents(_ context.Context, repo issues.RepoSpec, id uint64) ([]issues.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]issues.Event(nil), s.locks.Events[repo.URI][id]...), nil
+}
+
+// save writes locks to the file, if there is one.
+// s.mu must be held.
+func (s *lockStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.locks, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".locks-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
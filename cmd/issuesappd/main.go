@@ -0,0 +1,302 @@
+// issuesappd is a standalone issues app server. Issues are stored in a local
+// directory, and the server is configured with a YAML file, for example:
+//
+// 	listen: ":443"
+// 	tls:
+// 	  cert_file: /etc/issuesappd/cert.pem
+// 	  key_file: /etc/issuesappd/key.pem
+//
+// 	data_dir: /var/lib/issuesappd/issues
+// 	upload_dir: /var/lib/issuesappd/uploads
+// 	dashboard: /
+// 	issue_templates_dir: /etc/issuesappd/templates
+//
+// 	repos:
+// 	  - uri: example.org/project
+// 	    base_path: /project/issues
+// 	    roles:
+// 	      1: maintainer
+// 	    allowed_labels: [bug, question]
+// 	  - uri: example.org/another
+// 	    base_path: /another/issues
+//
+// 	default_role: reader
+//
+// 	domain: example.org
+// 	users_file: /etc/issuesappd/users.json
+// 	session_key_file: /var/lib/issuesappd/session.key
+// 	tokens_file: /var/lib/issuesappd/tokens.json
+// 	pins_file: /var/lib/issuesappd/pins.json
+// 	locks_file: /var/lib/issuesappd/locks.json
+//
+// The issues of each repository are served at its base path, and the API
+// used by the frontend is served at "/api/". Uploaded images are served at "/usercontent/".
+//
+// User accounts are read from users_file, in the format described by package
+// localusers, and users sign in at "/login". The -hash-password flag reads
+// a password from stdin and prints its hash, for use in that file.
+//
+// What users can do in each repository is decided by their roles, as defined
+// by policy.Roles. Roles are given to users by ID, and others get default_role.
+// Users who aren't maintainers can only apply the allowed_labels of a repository.
+//
+// Signed in users can create personal access tokens for API clients
+// at "/api/tokens/create". They're stored in tokens_file, if it's set,
+// and otherwise they only last until the server is restarted.
+//
+// Issue templates of a repository are Markdown files in the subdirectory of
+// issue_templates_dir named by its URI, e.g., "example.org/project/bug.md",
+// in the format described by issuesapp.Options.IssueTemplates.
+//
+// Maintainers can pin up to max_pinned issues per repository, which are shown
+// above the list of issues. They're stored in pins_file, if it's set,
+// and otherwise they're only kept until the server is restarted.
+//
+// Maintainers can lock conversations, so that only maintainers can comment
+// and react. Locks are stored in locks_file, if it's set,
+// and otherwise they're only kept until the server is restarted.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shurcooL/home/httputil"
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issues/fs"
+	"github.com/shurcooL/issuesapp"
+	"github.com/shurcooL/issuesapp/accesstoken"
+	"github.com/shurcooL/issuesapp/httphandler"
+	"github.com/shurcooL/issuesapp/httproute"
+	"github.com/shurcooL/issuesapp/localusers"
+	"github.com/shurcooL/webdavfs/vfsutil"
+	"golang.org/x/net/webdav"
+)
+
+var (
+	configFlag       = flag.String("config", "issuesappd.yaml", "Path to configuration file.")
+	hashPasswordFlag = flag.Bool("hash-password", false, "Read a password from stdin, print its hash, and exit.")
+)
+
+func main() {
+	flag.Parse()
+
+	if *hashPasswordFlag {
+		err := hashPassword()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	c, err := loadConfig(*configFlag)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = run(c)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func run(c config) error {
+	err := os.MkdirAll(c.DataDir, 0700)
+	if err != nil {
+		return err
+	}
+	root := webdav.Dir(c.DataDir)
+	for _, r := range c.Repos {
+		err := vfsutil.MkdirAll(context.Background(), root, path.Join(r.URI, "issues"), 0700)
+		if err != nil {
+			return err
+		}
+	}
+	if c.UploadDir != "" {
+		err = os.MkdirAll(c.UploadDir, 0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := loadSessionKey(c.SessionKeyFile)
+	if err != nil {
+		return err
+	}
+	localUsers, err := localusers.NewService(c.UsersFile, c.Domain, key)
+	if err != nil {
+		return err
+	}
+	tokenStore := accesstoken.NewMemoryStore()
+	if c.TokensFile != "" {
+		tokenStore, err = accesstoken.NewFileStore(c.TokensFile)
+		if err != nil {
+			return err
+		}
+	}
+	// The tokens service reports users of API requests with tokens as authenticated,
+	// so it's used as the users service of everything else.
+	tokens := accesstoken.NewService(tokenStore, localUsers)
+	service, err := fs.NewService(root, nil, nil, tokens)
+	if err != nil {
+		return err
+	}
+
+	pins, err := newPinStore(c.PinsFile)
+	if err != nil {
+		return err
+	}
+	locks, err := newLockStore(c.LocksFile, tokens)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:    c.Listen,
+		Handler: newHandler(c, service, localUsers, tokens, pins, locks),
+	}
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			log.Println("Shutdown:", err)
+		}
+	}()
+
+	log.Println("Started listening on", c.Listen)
+	switch c.TLS.CertFile {
+	case "":
+		err = srv.ListenAndServe()
+	default:
+		err = srv.ListenAndServeTLS(c.TLS.CertFile, c.TLS.KeyFile)
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// newHandler returns the handler of all requests to the server.
+func newHandler(c config, service issues.Service, localUsers *localusers.Service, tokens *accesstoken.Service, pins *pinStore, locks *lockStore) http.Handler {
+	usersService := tokens
+	roles := c.policy()
+	mux := http.NewServeMux()
+
+	mux.Handle("/login", httputil.ErrorHandler(localUsers, localUsers.Login))
+	mux.Handle("/logout", httputil.ErrorHandler(localUsers, localUsers.Logout))
+
+	// Register HTTP API endpoints. API clients can use personal access tokens.
+	api := http.NewServeMux()
+	apiHandler := httphandler.Issues{Issues: service, Policy: roles, Users: usersService, Locks: locks}
+	api.Handle(httproute.List, httputil.ErrorHandler(usersService, apiHandler.List))
+	api.Handle(httproute.Count, httputil.ErrorHandler(usersService, apiHandler.Count))
+	api.Handle(httproute.ListComments, httputil.ErrorHandler(usersService, apiHandler.ListComments))
+	api.Handle(httproute.ListEvents, httputil.ErrorHandler(usersService, apiHandler.ListEvents))
+	api.Handle(httproute.EditComment, httputil.ErrorHandler(usersService, apiHandler.EditComment))
+	tokensHandler := httphandler.Tokens{Tokens: tokens}
+	api.Handle(httproute.CreateToken, httputil.ErrorHandler(usersService, tokensHandler.Create))
+	api.Handle(httproute.ListTokens, httputil.ErrorHandler(usersService, tokensHandler.List))
+	api.Handle(httproute.RevokeToken, httputil.ErrorHandler(usersService, tokensHandler.Revoke))
+	if c.UploadDir != "" {
+		api.Handle("/api/usercontent", uploadHandler{dir: c.UploadDir, users: usersService})
+	}
+	mux.Handle("/api/", tokens.Authenticate(api))
+
+	if c.UploadDir != "" {
+		mux.Handle("/usercontent/", userContentHandler{dir: c.UploadDir})
+	}
+
+	opt := issuesapp.Options{
+		HeadPre: `<meta name="viewport" content="width=device-width">
+<style type="text/css">
+	body {
+		margin: 20px;
+		font-family: sans-serif;
+		font-size: 14px;
+		line-height: initial;
+		color: #373a3c;
+	}
+	.btn {
+		font-size: 11px;
+		line-height: 11px;
+		border-radius: 4px;
+		border: solid #d2d2d2 1px;
+		background-color: #fff;
+		box-shadow: 0 1px 1px rgba(0, 0, 0, .05);
+	}
+</style>`,
+		BodyPre: `<div style="max-width: 800px; margin: 0 auto 100px auto;">`,
+		// The fs service doesn't include bodies in List.
+		FetchBodies: true,
+		BodyTop: func(req *http.Request) ([]htmlg.Component, error) {
+			user, err := usersService.GetAuthenticated(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			return []htmlg.Component{userBar{User: user, ReturnURL: req.RequestURI}}, nil
+		},
+		SignIn:    localUsers.SignIn,
+		Pins:      pins,
+		Locks:     locks,
+		MaxPinned: c.MaxPinned,
+		Policy:    roles,
+	}
+	if c.IssueTemplatesDir != "" {
+		opt.IssueTemplates = http.Dir(c.IssueTemplatesDir)
+	}
+	issuesRouter := issuesapp.NewRouter(issuesapp.New(service, usersService, opt), repoResolver(c.Repos))
+	for _, r := range c.Repos {
+		mux.Handle(r.BasePath, issuesRouter)
+		mux.Handle(r.BasePath+"/", issuesRouter)
+	}
+
+	if c.Dashboard != "" {
+		var repos []issuesapp.DashboardRepo
+		for _, r := range c.Repos {
+			repos = append(repos, issuesapp.DashboardRepo{Repo: issues.RepoSpec{URI: r.URI}, BaseURI: r.BasePath})
+		}
+		dashboard := issuesapp.NewDashboard(service, usersService, repos, opt)
+		prefix := strings.TrimSuffix(c.Dashboard, "/")
+		dashboardRouter := issuesapp.NewRouter(dashboard, func(context.Context, string) (issues.RepoSpec, string, bool) {
+			return issues.RepoSpec{}, prefix, true
+		})
+		if prefix != "" {
+			mux.Handle(prefix, dashboardRouter)
+		}
+		mux.Handle(prefix+"/", dashboardRouter)
+	}
+
+	return localUsers.Authenticate(mux)
+}
+
+// repoResolver returns a resolver of repos by their base paths.
+// The repository with the longest matching base path is used.
+func repoResolver(repos []repoConfig) issuesapp.RepoResolver {
+	return func(_ context.Context, path string) (issues.RepoSpec, string, bool) {
+		var match *repoConfig
+		for i, r := range repos {
+			if path != r.BasePath && !strings.HasPrefix(path, r.BasePath+"/") {
+				continue
+			}
+			if match == nil || len(r.BasePath) > len(match.BasePath) {
+				match = &repos[i]
+			}
+		}
+		if match == nil {
+			return issues.RepoSpec{}, "", false
+		}
+		return issues.RepoSpec{URI: match.URI}, match.BasePath, true
+	}
+}
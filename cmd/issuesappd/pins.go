@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/shurcooL/issues"
+)
+
+// pinStore is an issuesapp.Pinner that stores pinned issues of all
+// repositories in a JSON file, keyed by repository URI. If path is empty,
+// pinned issues are only kept in memory.
+type pinStore struct {
+	path string
+
+	mu   sync.Mutex
+	pins map[string][]uint64
+}
+
+// newPinStore returns a pin store that uses the file at path, if it's not empty.
+// The file is created when an issue is first pinned.
+func newPinStore(path string) (*pinStore, error) {
+	s := &pinStore{path: path, pins: make(map[string][]uint64)}
+	if path == "" {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &s.pins)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *pinStore) ListPinned(_ context.Context, repo issues.RepoSpec) ([]uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64(nil), s.pins[repo.URI]...), nil
+}
+
+func (s *pinStore) SetPinned(_ context.Context, repo issues.RepoSpec, id uint64, pinned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []uint64
+	for _, i := range s.pins[repo.URI] {
+		if i != id {
+			ids = append(ids, i)
+		}
+	}
+	if pinned {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		delete(s.pins, repo.URI)
+	} else {
+		s.pins[repo.URI] = ids
+	}
+	return s.save()
+}
+
+// save writes pinned issues to the file, if there is one.
+// s.mu must be held.
+func (s *pinStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.pins, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".pins-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shurcooL/users"
+)
+
+// maxUploadSize is the maximum size of an uploaded image.
+const maxUploadSize = 10 << 20
+
+// uploadHandler stores images uploaded by authenticated users in dir,
+// as pasted into comments by the issues app frontend.
+// They're named by the hash of their content.
+type uploadHandler struct {
+	dir   string
+	users users.Service
+}
+
+func (h uploadHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	url, err := h.upload(req)
+	// The frontend expects a JSON response with either a URL or an error.
+	var resp struct {
+		URL   string `json:",omitempty"`
+		Error string `json:",omitempty"`
+	}
+	switch err {
+	case nil:
+		resp.URL = url
+	default:
+		log.Println("upload:", err)
+		resp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// upload stores the image in req, and returns its URL.
+func (h uploadHandler) upload(req *http.Request) (url string, err error) {
+	user, err := h.users.GetAuthenticatedSpec(req.Context())
+	if err != nil {
+		return "", err
+	}
+	if user.ID == 0 {
+		return "", errors.New("sign in to upload images")
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "image/png" {
+		return "", errors.New("only PNG images can be uploaded")
+	}
+	b, err := ioutil.ReadAll(http.MaxBytesReader(nil, req.Body, maxUploadSize))
+	if _, ok := err.(*http.MaxBytesError); ok {
+		return "", errors.New("image is too large")
+	} else if err != nil {
+		return "", err
+	}
+	// Images are served from the same origin as the app,
+	// so check that the content really is a PNG image.
+	if _, err := png.DecodeConfig(bytes.NewReader(b)); err != nil {
+		return "", errors.New("image is not a valid PNG image")
+	}
+	sum := sha256.Sum256(b)
+	name := hex.EncodeToString(sum[:]) + ".png"
+	path := filepath.Join(h.dir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// Write to a temporary file first, so that a partially
+		// written image is never served.
+		f, err := ioutil.TempFile(h.dir, ".upload-")
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(b)
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), path)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+	}
+	return "/usercontent/" + name, nil
+}
+
+// userContentHandler serves images uploaded to dir.
+type userContentHandler struct {
+	dir string
+}
+
+func (h userContentHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/usercontent/")
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable") // Names are content hashes.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, req, filepath.Join(h.dir, name))
+}
@@ -2,6 +2,10 @@
 package common
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
 	"github.com/shurcooL/issues"
 	"github.com/shurcooL/users"
 )
@@ -14,4 +18,26 @@ type State struct {
 	CurrentUser      users.User
 	DisableReactions bool
 	DisableUsers     bool
+	ServerPreview    bool `json:",omitempty"` // ServerPreview is whether Markdown previews are rendered by the server.
+	CanReact         bool `json:",omitempty"` // CanReact is whether the current user can react to comments of the current issue.
+}
+
+// Event types of locking and unlocking the conversation of an issue.
+// Package issues doesn't define them, so issues services that support
+// locking conversations use these.
+const (
+	Locked   issues.EventType = "locked"
+	Unlocked issues.EventType = "unlocked"
+)
+
+// BodyVersion returns an opaque version of a comment with the given body.
+// It's used to detect when a comment was edited by someone else
+// after the current user started editing it.
+//
+// Line endings are normalized first, because the frontend computes the version
+// of a body it reads back from an HTML attribute, where CRLF becomes LF.
+func BodyVersion(body string) string {
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:16])
 }
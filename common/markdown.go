@@ -0,0 +1,128 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/shurcooL/reactions"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// RewriteText parses rendered HTML and calls f on each text node that isn't inside
+// a link or code. If f returns non-nil nodes, they replace the text node.
+// It returns the rewritten HTML, or rendered as is if it can't be parsed.
+func RewriteText(rendered []byte, f func(text string) []*html.Node) []byte {
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.ElementNode && (n.DataAtom == atom.A || n.DataAtom == atom.Code || n.DataAtom == atom.Pre):
+			return
+		case n.Type == html.TextNode:
+			ns := f(n.Data)
+			if ns == nil {
+				return
+			}
+			for _, c := range ns {
+				n.Parent.InsertBefore(c, n)
+			}
+			n.Parent.RemoveChild(n)
+			return
+		}
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling // c may get replaced.
+			walk(c)
+			c = next
+		}
+	}
+	return rewrite(rendered, walk)
+}
+
+// RewriteNodes parses rendered HTML and calls f on each of its nodes, in depth-first order.
+// f may modify the node and replace its children, but not replace the node itself.
+// It returns the rewritten HTML, or rendered as is if it can't be parsed.
+func RewriteNodes(rendered []byte, f func(n *html.Node)) []byte {
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		f(n)
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	return rewrite(rendered, walk)
+}
+
+// rewrite parses rendered HTML, calls walk on each top-level node, and renders the result.
+// Top-level nodes have a parent during walk, so that they can be replaced.
+// It returns rendered as is if it can't be parsed.
+func rewrite(rendered []byte, walk func(n *html.Node)) []byte {
+	context := &html.Node{Type: html.ElementNode, Data: atom.Div.String(), DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(bytes.NewReader(rendered), context)
+	if err != nil {
+		return rendered
+	}
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		wrapper := &html.Node{Type: html.ElementNode, Data: atom.Div.String(), DataAtom: atom.Div}
+		wrapper.AppendChild(n)
+		walk(n)
+		for c := wrapper.FirstChild; c != nil; c = c.NextSibling {
+			err := html.Render(&buf, c)
+			if err != nil {
+				return rendered
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// emojiPattern matches an emoji shortcode, like ":tada:".
+var emojiPattern = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+
+// RenderEmojis returns rendered HTML with emoji shortcodes, like ":tada:",
+// replaced by emoji images from the same tilemap as reactions.
+// Unknown shortcodes are left as is.
+func RenderEmojis(rendered []byte) []byte {
+	return RewriteText(rendered, func(text string) []*html.Node {
+		var ns []*html.Node
+		var last int
+		for _, m := range emojiPattern.FindAllStringIndex(text, -1) {
+			shortcode := text[m[0]:m[1]]
+			position := reactions.Position(shortcode)
+			if position == "" {
+				continue
+			}
+			ns = append(ns, &html.Node{Type: html.TextNode, Data: text[last:m[0]]})
+			ns = append(ns, Emoji(shortcode, position))
+			last = m[1]
+		}
+		if ns == nil {
+			return nil
+		}
+		return append(ns, &html.Node{Type: html.TextNode, Data: text[last:]})
+	})
+}
+
+// Emoji returns an emoji image for shortcode at position within the emojis tilemap.
+//
+// 	<span class="emoji-outer emoji-sizer" title="{{.shortcode}}">
+// 		<span class="emoji-inner" style="background-position: {{.position}};"></span>
+// 	</span>
+func Emoji(shortcode, position string) *html.Node {
+	outer := &html.Node{
+		Type: html.ElementNode, Data: atom.Span.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "emoji-outer emoji-sizer"},
+			{Key: atom.Title.String(), Val: shortcode},
+		},
+	}
+	outer.AppendChild(&html.Node{
+		Type: html.ElementNode, Data: atom.Span.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "emoji-inner"},
+			{Key: atom.Style.String(), Val: fmt.Sprintf("background-position: %s;", position)},
+		},
+	})
+	return outer
+}
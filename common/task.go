@@ -0,0 +1,96 @@
+package common
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// taskPattern matches a task list item line, like "- [x] Done",
+// the way GitHub Flavored Markdown renders it as a checkbox.
+// The submatch is the state of its checkbox.
+var taskPattern = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\] +\S`)
+
+// fencePattern matches a line that opens or closes a fenced code block.
+var fencePattern = regexp.MustCompile("^ {0,3}(?:```|~~~)")
+
+// tasks calls f with each task list item line of the Markdown body,
+// in order of appearance, along with the byte offset of its checkbox state.
+// Task list items inside fenced code blocks are skipped.
+func tasks(body string, f func(offset int, checked bool)) {
+	var inFence bool
+	var offset int
+	for _, line := range strings.SplitAfter(body, "\n") {
+		switch {
+		case fencePattern.MatchString(line):
+			inFence = !inFence
+		case !inFence:
+			if m := taskPattern.FindStringSubmatchIndex(line); m != nil {
+				f(offset+m[2], line[m[2]] != ' ')
+			}
+		}
+		offset += len(line)
+	}
+}
+
+// Tasks returns the number of completed tasks and the total number of tasks
+// in the task lists of the Markdown body.
+func Tasks(body string) (done, total int) {
+	tasks(body, func(_ int, checked bool) {
+		if checked {
+			done++
+		}
+		total++
+	})
+	return done, total
+}
+
+// SetTask returns the Markdown body with task i (counting from 0 in order of appearance)
+// checked or unchecked. It reports false if body has no task i.
+func SetTask(body string, i int, checked bool) (string, bool) {
+	offset := -1
+	var n int
+	tasks(body, func(o int, _ bool) {
+		if n == i {
+			offset = o
+		}
+		n++
+	})
+	if offset == -1 {
+		return body, false
+	}
+	state := " "
+	if checked {
+		state = "x"
+	}
+	return body[:offset] + state + body[offset+1:], true
+}
+
+// EnableTasks returns rendered HTML of the Markdown body with the disabled
+// task list checkboxes that GitHub Flavored Markdown renders made enabled,
+// so that they can be toggled. They're given the "task-list-item-checkbox" class.
+//
+// Some task list items aren't rendered as checkboxes, e.g., in loose lists.
+// Then the checkboxes can't be reliably matched to tasks in body,
+// so rendered is returned as is. So is rendered of a body without tasks.
+func EnableTasks(rendered []byte, body string) []byte {
+	if _, total := Tasks(body); total == 0 || total != bytes.Count(rendered, []byte(`<input type="checkbox"`)) {
+		return rendered
+	}
+	return RewriteNodes(rendered, func(n *html.Node) {
+		if n.Type != html.ElementNode || n.DataAtom != atom.Input {
+			return
+		}
+		var attr []html.Attribute
+		for _, a := range n.Attr {
+			if a.Key == atom.Disabled.String() {
+				continue
+			}
+			attr = append(attr, a)
+		}
+		n.Attr = append(attr, html.Attribute{Key: atom.Class.String(), Val: "task-list-item-checkbox"})
+	})
+}
@@ -0,0 +1,53 @@
+package common
+
+import "testing"
+
+func TestTasks(t *testing.T) {
+	tests := []struct {
+		in              string
+		wantDone, total int
+	}{
+		{"", 0, 0},
+		{"- [x] Done.\n- [ ] Not done.", 1, 2},
+		{"* [X] Done.\n+ [ ] Not done.\n1. [x] Done.\n2) [ ] Not done.", 2, 4},
+		{"- [x] Done.\r\n- [ ] Not done.\r\n", 1, 2},
+		// Nested lists.
+		{"- [x] Parent.\n  - [ ] Child.\n    - [x] Grandchild.", 2, 3},
+		// Fenced code blocks.
+		{"```\n- [x] Code.\n```\n- [ ] Task.", 0, 1},
+		{"~~~md\r\n- [x] Code.\r\n~~~\r\n- [ ] Task.", 0, 1},
+		{"```\n- [x] Unterminated code.", 0, 0},
+		// Not tasks.
+		{"- [x]\n- [y] No.\n-[x] No.\n[x] No.\n- [x]\tNo.", 0, 0},
+	}
+	for _, tc := range tests {
+		done, total := Tasks(tc.in)
+		if done != tc.wantDone || total != tc.total {
+			t.Errorf("Tasks(%q): got %d of %d, want %d of %d", tc.in, done, total, tc.wantDone, tc.total)
+		}
+	}
+}
+
+func TestSetTask(t *testing.T) {
+	tests := []struct {
+		in      string
+		i       int
+		checked bool
+		want    string
+		wantOK  bool
+	}{
+		{"- [ ] A.\n- [ ] B.", 1, true, "- [ ] A.\n- [x] B.", true},
+		{"- [X] A.", 0, false, "- [ ] A.", true},
+		{"- [x] A.", 0, true, "- [x] A.", true},
+		{"- [x] A.\r\n  - [x] B.\r\n", 1, false, "- [x] A.\r\n  - [ ] B.\r\n", true},
+		{"```\n- [ ] Code.\n```\n- [ ] A.", 0, true, "```\n- [ ] Code.\n```\n- [x] A.", true},
+		{"- [ ] A.", 1, true, "- [ ] A.", false},
+		{"- [ ] A.", -1, true, "- [ ] A.", false},
+	}
+	for _, tc := range tests {
+		got, ok := SetTask(tc.in, tc.i, tc.checked)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("SetTask(%q, %d, %v): got %q, %v, want %q, %v", tc.in, tc.i, tc.checked, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
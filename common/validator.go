@@ -0,0 +1,103 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Validator computes the HTTP validators of a response, i.e., its entity tag
+// and last modification time, from the data the response is based on.
+// The zero value is ready for use.
+//
+// Only responses based on data that's never changed after it's created,
+// like events, should record a modification time. Other data, like issues
+// and comments, can change without a new time (e.g., when an issue is closed
+// or reacted to), so responses based on it only have an entity tag.
+type Validator struct {
+	hash    hash.Hash
+	modTime time.Time
+	err     error // Non-nil if some data couldn't be added.
+}
+
+// Add adds x to the data the response is based on. The entity tag changes
+// whenever x does. x is JSON encoded; if that fails, there are no validators.
+func (v *Validator) Add(x interface{}) {
+	if v.hash == nil {
+		v.hash = sha256.New()
+	}
+	if err := json.NewEncoder(v.hash).Encode(x); err != nil {
+		v.err = err
+	}
+}
+
+// Modified records that the data the response is based on was created at t.
+// The last modification time is the latest t recorded.
+func (v *Validator) Modified(t time.Time) {
+	if t.After(v.modTime) {
+		v.modTime = t
+	}
+}
+
+// ETag returns the weak entity tag of the response, or "" if there isn't one.
+func (v *Validator) ETag() string {
+	if v.hash == nil || v.err != nil {
+		return ""
+	}
+	return `W/"` + hex.EncodeToString(v.hash.Sum(nil)[:16]) + `"`
+}
+
+// CheckNotModified sets the ETag and Last-Modified headers of the response,
+// and reports whether req is a conditional GET request for a response
+// that hasn't changed. If so, it responds with 304 Not Modified,
+// and the caller should not write anything else.
+//
+// As in RFC 7232, If-Modified-Since is only used if there's no If-None-Match,
+// and only if a modification time was recorded.
+// Responses can depend on the current user, so they're marked as private
+// and to be revalidated before use.
+func (v *Validator) CheckNotModified(w http.ResponseWriter, req *http.Request) bool {
+	etag := v.ETag()
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	if !v.modTime.IsZero() {
+		w.Header().Set("Last-Modified", v.modTime.UTC().Format(http.TimeFormat))
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	var notModified bool
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		notModified = etagMatch(inm, etag)
+	} else if t, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && !v.modTime.IsZero() {
+		notModified = !v.modTime.Truncate(time.Second).After(t)
+	}
+	if !notModified {
+		return false
+	}
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch reports whether the If-None-Match header value inm matches etag,
+// using the weak comparison function.
+func etagMatch(inm, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(inm, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/shurcooL/htmlg"
 	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
 	"github.com/shurcooL/octicon"
 	"github.com/shurcooL/users"
 	"golang.org/x/net/html"
@@ -74,6 +75,11 @@ func (e Event) icon() *html.Node {
 		icon = octicon.Milestone()
 	case issues.CommentDeleted:
 		icon = octicon.X()
+	case common.Locked:
+		icon = octicon.Lock()
+		color, backgroundColor = "#fff", "#24292e"
+	case common.Unlocked:
+		icon = octicon.Key()
 	default:
 		icon = octicon.PrimitiveDot()
 	}
@@ -139,6 +145,10 @@ func (e Event) text() []*html.Node {
 		return []*html.Node{htmlg.Text("removed from the "), htmlg.Strong(e.Event.Milestone.Name), htmlg.Text(" milestone")}
 	case issues.CommentDeleted:
 		return []*html.Node{htmlg.Text("deleted a comment")}
+	case common.Locked:
+		return []*html.Node{htmlg.Text("locked this conversation")}
+	case common.Unlocked:
+		return []*html.Node{htmlg.Text("unlocked this conversation")}
 	default:
 		return []*html.Node{htmlg.Text(string(e.Event.Type))}
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/shurcooL/htmlg"
 	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
 	"github.com/shurcooL/octicon"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -55,20 +56,34 @@ func (i Issues) Render() []*html.Node {
 
 // IssueEntry is an entry within the list of issues.
 type IssueEntry struct {
-	Issue  issues.Issue
-	Unread bool // Unread indicates whether the issue contains unread notifications for authenticated user.
+	Issue  issues.Issue // Issue.Body is used to display task progress, if it has task list items.
+	Unread bool         // Unread indicates whether the issue contains unread notifications for authenticated user.
+
+	// Repo is displayed before the title of an issue listed along with
+	// issues of other repositories. It can be nil.
+	Repo *RepoBadge
+
+	// Selectable is whether the entry has a checkbox for selecting
+	// the issue, as part of the form with ID BulkFormID.
+	Selectable bool
 
 	// TODO, THINK: This is router details, can it be factored out or cleaned up?
 	BaseURI string
 }
 
+// BulkFormID is the ID of the form that checkboxes of selectable
+// issue entries are part of.
+const BulkFormID = "bulk-triage"
+
 func (i IssueEntry) Render() []*html.Node {
 	// TODO: Make this much nicer.
 	// <div class="list-entry-body multilist-entry"{{if .Unread}} style="box-shadow: 2px 0 0 #4183c4 inset;"{{end}}>
 	// 	<div style="display: flex;">
+	// 		{{if .Selectable}}<input type="checkbox" class="bulk-select" form="bulk-triage" name="issue" value="{{.ID}}">{{end}}
 	// 		{{render (issueIcon .State)}}
 	// 		<div style="flex-grow: 1;">
 	// 			<div>
+	// 				{{with .Repo}}{{render .}}{{end}}
 	// 				<a class="black" href="{{state.BaseURI}}/{{.ID}}"><strong>{{.Title}}</strong></a>
 	// 				{{range .Labels}}{{render (label .)}}{{end}}
 	// 			</div>
@@ -82,6 +97,19 @@ func (i IssueEntry) Render() []*html.Node {
 		Type: html.ElementNode, Data: atom.Div.String(),
 		Attr: []html.Attribute{{Key: atom.Style.String(), Val: "display: flex;"}},
 	}
+	if i.Selectable {
+		div.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.Input.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Type.String(), Val: "checkbox"},
+				{Key: atom.Class.String(), Val: "bulk-select"},
+				{Key: atom.Form.String(), Val: BulkFormID},
+				{Key: atom.Name.String(), Val: "issue"},
+				{Key: atom.Value.String(), Val: fmt.Sprint(i.Issue.ID)},
+				{Key: "aria-label", Val: fmt.Sprintf("Select issue #%d", i.Issue.ID)},
+			},
+		})
+	}
 	htmlg.AppendChildren(div, IssueIcon{State: i.Issue.State}.Render()...)
 
 	titleAndByline := &html.Node{
@@ -89,16 +117,18 @@ func (i IssueEntry) Render() []*html.Node {
 		Attr: []html.Attribute{{Key: atom.Style.String(), Val: "flex-grow: 1;"}},
 	}
 	{
-		title := htmlg.Div(
-			&html.Node{
-				Type: html.ElementNode, Data: atom.A.String(),
-				Attr: []html.Attribute{
-					{Key: atom.Class.String(), Val: "black"},
-					{Key: atom.Href.String(), Val: fmt.Sprintf("%s/%d", i.BaseURI, i.Issue.ID)},
-				},
-				FirstChild: htmlg.Strong(i.Issue.Title),
+		title := htmlg.Div()
+		if i.Repo != nil {
+			htmlg.AppendChildren(title, i.Repo.Render()...)
+		}
+		title.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.A.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Class.String(), Val: "black"},
+				{Key: atom.Href.String(), Val: fmt.Sprintf("%s/%d", i.BaseURI, i.Issue.ID)},
 			},
-		)
+			FirstChild: htmlg.Strong(i.Issue.Title),
+		})
 		for _, l := range i.Issue.Labels {
 			span := &html.Node{
 				Type: html.ElementNode, Data: atom.Span.String(),
@@ -114,6 +144,10 @@ func (i IssueEntry) Render() []*html.Node {
 		byline.AppendChild(htmlg.Text(fmt.Sprintf("#%d opened ", i.Issue.ID)))
 		htmlg.AppendChildren(byline, Time{Time: i.Issue.CreatedAt}.Render()...)
 		byline.AppendChild(htmlg.Text(fmt.Sprintf(" by %s", i.Issue.User.Login)))
+		if done, total := common.Tasks(i.Issue.Body); total > 0 {
+			byline.AppendChild(htmlg.Text(" · "))
+			htmlg.AppendChildren(byline, TaskProgress{Done: done, Total: total}.Render()...)
+		}
 		titleAndByline.AppendChild(byline)
 	}
 	div.AppendChild(titleAndByline)
@@ -144,3 +178,94 @@ func (i IssueEntry) Render() []*html.Node {
 	}
 	return []*html.Node{listEntryDiv}
 }
+
+// PinnedIssues is a component that displays pinned issues as cards,
+// above the list of issues.
+type PinnedIssues struct {
+	Issues  []issues.Issue
+	BaseURI string
+}
+
+func (p PinnedIssues) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	// <div class="pinned-issues">
+	// 	{{range .Issues}}
+	// 		<div class="pinned-issue">
+	// 			<div class="gray tiny">{{octicon "pin"}} Pinned</div>
+	// 			<div>{{render (issueIcon .State)}}<a class="black" href="{{$.BaseURI}}/{{.ID}}"><strong>{{.Title}}</strong></a></div>
+	// 			<div class="gray tiny">#{{.ID}} opened {{render (time .CreatedAt)}} by {{.User.Login}}</div>
+	// 		</div>
+	// 	{{end}}
+	// </div>
+	if len(p.Issues) == 0 {
+		return nil
+	}
+	div := htmlg.DivClass("pinned-issues")
+	for _, i := range p.Issues {
+		header := htmlg.DivClass("gray tiny", octicon.Pin(), htmlg.Text(" Pinned"))
+
+		title := htmlg.DivClass("pinned-issue-title")
+		htmlg.AppendChildren(title, IssueIcon{State: i.State}.Render()...)
+		title.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.A.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Class.String(), Val: "black"},
+				{Key: atom.Href.String(), Val: fmt.Sprintf("%s/%d", p.BaseURI, i.ID)},
+			},
+			FirstChild: htmlg.Strong(i.Title),
+		})
+
+		byline := htmlg.DivClass("gray tiny")
+		byline.AppendChild(htmlg.Text(fmt.Sprintf("#%d opened ", i.ID)))
+		htmlg.AppendChildren(byline, Time{Time: i.CreatedAt}.Render()...)
+		byline.AppendChild(htmlg.Text(fmt.Sprintf(" by %s", i.User.Login)))
+
+		div.AppendChild(htmlg.DivClass("pinned-issue", header, title, byline))
+	}
+	return []*html.Node{div}
+}
+
+// RepoBadge is a component that displays the repository of an issue.
+type RepoBadge struct {
+	Repo issues.RepoSpec
+	URL  string // URL the badge links to, e.g., to only list issues of Repo.
+}
+
+func (r RepoBadge) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	// <a class="repo-badge" href="{{.URL}}" title="{{.Repo.URI}}">{{.Repo.URI}}</a>
+	a := &html.Node{
+		Type: html.ElementNode, Data: atom.A.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "repo-badge"},
+			{Key: atom.Href.String(), Val: r.URL},
+			{Key: atom.Title.String(), Val: r.Repo.URI},
+		},
+		FirstChild: htmlg.Text(r.Repo.URI),
+	}
+	return []*html.Node{a}
+}
+
+// TaskProgress is a component that displays how many tasks
+// of an issue's task lists are completed.
+type TaskProgress struct {
+	Done, Total int
+}
+
+func (t TaskProgress) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	// <span class="task-progress" title="{{.Done}} of {{.Total}} tasks completed">
+	// 	{{octicon "checklist"}} {{.Done}} of {{.Total}} tasks
+	// 	<span class="task-progress-bar"><span style="width: {{percent}}%;"></span></span>
+	// </span>
+	span := htmlg.SpanClass("task-progress")
+	span.Attr = append(span.Attr, html.Attribute{Key: atom.Title.String(), Val: fmt.Sprintf("%d of %d tasks completed", t.Done, t.Total)})
+	span.AppendChild(octicon.Checklist())
+	span.AppendChild(htmlg.Text(fmt.Sprintf(" %d of %d tasks ", t.Done, t.Total)))
+	bar := htmlg.SpanClass("task-progress-bar", &html.Node{
+		Type: html.ElementNode, Data: atom.Span.String(),
+		Attr: []html.Attribute{{Key: atom.Style.String(), Val: fmt.Sprintf("width: %d%%;", 100*t.Done/t.Total)}},
+	})
+	span.AppendChild(bar)
+	return []*html.Node{span}
+}
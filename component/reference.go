@@ -0,0 +1,104 @@
+package component
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/octicon"
+	"github.com/shurcooL/users"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// IssueReference is a link to an issue, written as "#123",
+// with a hover card showing the issue's state and title.
+type IssueReference struct {
+	Issue   issues.Issue
+	BaseURI string
+}
+
+func (r IssueReference) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	// <a class="issue-reference" href="{{.BaseURI}}/{{.Issue.ID}}">#{{.Issue.ID}}
+	// 	<span class="issue-reference-card">{{render (issueIcon .Issue.State)}}<strong>{{.Issue.Title}}</strong> <span class="gray">#{{.Issue.ID}}</span></span>
+	// </a>
+	card := htmlg.SpanClass("issue-reference-card")
+	htmlg.AppendChildren(card, IssueIcon{State: r.Issue.State}.Render()...)
+	card.AppendChild(htmlg.Strong(r.Issue.Title))
+	card.AppendChild(htmlg.Text(" "))
+	card.AppendChild(htmlg.SpanClass("gray", htmlg.Text(fmt.Sprintf("#%d", r.Issue.ID))))
+	a := &html.Node{
+		Type: html.ElementNode, Data: atom.A.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "issue-reference"},
+			{Key: atom.Href.String(), Val: fmt.Sprintf("%s/%d", r.BaseURI, r.Issue.ID)},
+		},
+	}
+	a.AppendChild(htmlg.Text(fmt.Sprintf("#%d", r.Issue.ID)))
+	a.AppendChild(card)
+	return []*html.Node{a}
+}
+
+// CrossReferenceEvent is an event component for when an issue
+// was mentioned in another issue.
+type CrossReferenceEvent struct {
+	Actor     users.User
+	CreatedAt time.Time
+	Source    issues.Issue // Source is the issue that mentioned this issue.
+	BaseURI   string
+}
+
+func (e CrossReferenceEvent) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	// <div class="list-entry event event-cross-referenced">
+	// 	<span class="event-icon">{{octicon "bookmark"}}</span>
+	// 	<div class="event-header">
+	// 		<img class="inline-avatar" width="16" height="16" src="{{.Actor.AvatarURL}}">
+	// 		{{render (user .Actor)}} mentioned this issue {{render (time .CreatedAt)}}
+	// 		<div>{{render (issueIcon .Source.State)}}<a class="black" href="{{.BaseURI}}/{{.Source.ID}}"><strong>{{.Source.Title}}</strong></a> <span class="gray">#{{.Source.ID}}</span></div>
+	// 	</div>
+	// </div>
+
+	div := htmlg.DivClass("event-header")
+	image := &html.Node{
+		Type: html.ElementNode, Data: atom.Img.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Style.String(), Val: "width: 16px; height: 16px; border-radius: 2px; vertical-align: middle; margin-right: 4px;"},
+			{Key: atom.Src.String(), Val: e.Actor.AvatarURL},
+		},
+	}
+	div.AppendChild(image)
+	htmlg.AppendChildren(div, User{e.Actor}.Render()...)
+	div.AppendChild(htmlg.Text(" mentioned this issue "))
+	htmlg.AppendChildren(div, Time{e.CreatedAt}.Render()...)
+
+	source := htmlg.Div()
+	htmlg.AppendChildren(source, IssueIcon{State: e.Source.State}.Render()...)
+	source.AppendChild(&html.Node{
+		Type: html.ElementNode, Data: atom.A.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "black"},
+			{Key: atom.Href.String(), Val: fmt.Sprintf("%s/%d", e.BaseURI, e.Source.ID)},
+		},
+		FirstChild: htmlg.Strong(e.Source.Title),
+	})
+	source.AppendChild(htmlg.Text(" "))
+	source.AppendChild(htmlg.SpanClass("gray", htmlg.Text(fmt.Sprintf("#%d", e.Source.ID))))
+	div.AppendChild(source)
+
+	icon := &html.Node{
+		Type: html.ElementNode, Data: atom.Span.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "event-icon"},
+			{Key: atom.Style.String(), Val: "color: #767676; background-color: #f3f3f3;"},
+		},
+		FirstChild: octicon.Bookmark(),
+	}
+	outerDiv := htmlg.DivClass("list-entry event event-cross-referenced",
+		icon,
+		div,
+	)
+	return []*html.Node{outerDiv}
+}
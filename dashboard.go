@@ -0,0 +1,201 @@
+package issuesapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/component"
+	"github.com/shurcooL/users"
+)
+
+// DashboardRepo is a repository whose issues are displayed on a dashboard.
+type DashboardRepo struct {
+	Repo    issues.RepoSpec
+	BaseURI string // Base URI of the issues app for Repo, used for links to its issues.
+
+	// Service is the issues service of Repo. It can be nil,
+	// in which case the service of the dashboard is used.
+	Service issues.Service
+}
+
+// NewDashboard returns a dashboard http.Handler that displays the issues of repos
+// in one combined list, using given services and options. Each issue links to
+// the issues app for its repository, and has a badge that filters the list
+// to that repository. Issues of repos are listed from service, unless
+// DashboardRepo.Service is set for repositories that use another backend.
+//
+// In order to serve HTTP requests, the returned http.Handler expects each incoming
+// request to have the base URI of the dashboard provided to it via BaseURIContextKey
+// context key. Assets are served by the dashboard too, under "/assets/".
+func NewDashboard(service issues.Service, users users.Service, repos []DashboardRepo, opt Options) http.Handler {
+	d := &dashboard{
+		handler: newHandler(service, users, opt),
+		repos:   repos,
+	}
+	return &errorHandler{
+		handler: d.ServeHTTP,
+		users:   users,
+	}
+}
+
+// dashboard handles all requests to a dashboard.
+type dashboard struct {
+	*handler
+	repos []DashboardRepo
+}
+
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
+	if _, ok := req.Context().Value(BaseURIContextKey).(string); !ok {
+		return fmt.Errorf("request to %v doesn't have issuesapp.BaseURIContextKey context key set", req.URL.Path)
+	}
+
+	// Handle "/assets/...".
+	if d.serveAssets(w, req) {
+		return nil
+	}
+
+	// Handle "/".
+	if req.URL.Path == "/" {
+		return d.DashboardHandler(w, req)
+	}
+
+	return httperror.HTTP{Code: http.StatusNotFound, Err: errors.New("no route")}
+}
+
+const (
+	// repoQueryKey is name of query key for filtering dashboard issues by repository.
+	repoQueryKey = "repo"
+)
+
+func (d *dashboard) DashboardHandler(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != http.MethodGet {
+		return httperror.Method{Allowed: []string{http.MethodGet}}
+	}
+	state, err := d.state(req, 0)
+	if err != nil {
+		return err
+	}
+	query := req.URL.Query()
+	filter, err := stateFilter(query)
+	if err != nil {
+		return httperror.BadRequest{Err: err}
+	}
+	repos := d.repos
+	if uri := query.Get(repoQueryKey); uri != "" {
+		repos = nil
+		for _, r := range d.repos {
+			if r.Repo.URI == uri {
+				repos = append(repos, r)
+			}
+		}
+		if len(repos) == 0 {
+			return httperror.BadRequest{Err: fmt.Errorf("unknown repository %q", uri)}
+		}
+	}
+
+	// Query the issues of all repositories concurrently.
+	results := make([]repoIssues, len(repos))
+	var wg sync.WaitGroup
+	for i, r := range repos {
+		wg.Add(1)
+		go func(i int, r DashboardRepo) {
+			defer wg.Done()
+			results[i] = d.listRepo(req.Context(), state, r, filter)
+		}(i, r)
+	}
+	wg.Wait()
+
+	var (
+		es                     []component.IssueEntry
+		openCount, closedCount uint64
+	)
+	for i, r := range results {
+		if r.err != nil {
+			return fmt.Errorf("%s: %v", repos[i].Repo.URI, r.err)
+		}
+		// Make the badge of each issue toggle filtering by its repository.
+		badgeQuery := url.Values{}
+		for k, v := range query {
+			badgeQuery[k] = v
+		}
+		switch query.Get(repoQueryKey) {
+		case "":
+			badgeQuery.Set(repoQueryKey, repos[i].Repo.URI)
+		default:
+			badgeQuery.Del(repoQueryKey)
+		}
+		badgeURL := (&url.URL{Path: state.BaseURI + state.ReqPath, RawQuery: badgeQuery.Encode()}).String()
+		for _, e := range r.entries {
+			e.Repo = &component.RepoBadge{Repo: repos[i].Repo, URL: badgeURL}
+			es = append(es, e)
+		}
+		openCount += r.openCount
+		closedCount += r.closedCount
+	}
+	sort.SliceStable(es, func(i, j int) bool { return es[i].Issue.CreatedAt.After(es[j].Issue.CreatedAt) })
+	state.Issues = component.Issues{
+		IssuesNav: component.IssuesNav{
+			OpenCount:     openCount,
+			ClosedCount:   closedCount,
+			Path:          state.BaseURI + state.ReqPath,
+			Query:         query,
+			StateQueryKey: stateQueryKey,
+		},
+		Filter:  filter,
+		Entries: es,
+	}
+	v := d.validator(&state)
+	if v.CheckNotModified(w, req) {
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = d.static.ExecuteTemplate(w, "dashboard.html.tmpl", &state)
+	if err != nil {
+		return fmt.Errorf("d.static.ExecuteTemplate: %v", err)
+	}
+	return nil
+}
+
+// repoIssues are the issues of a repository displayed on a dashboard.
+type repoIssues struct {
+	entries                []component.IssueEntry
+	openCount, closedCount uint64
+	err                    error
+}
+
+// listRepo lists the issues of r that match filter, and counts its open and closed issues.
+func (d *dashboard) listRepo(ctx context.Context, s state, r DashboardRepo, filter issues.StateFilter) repoIssues {
+	service := d.is
+	if r.Service != nil {
+		service = r.Service
+	}
+	is, err := service.List(ctx, r.Repo, issues.IssueListOptions{State: filter})
+	if err != nil {
+		return repoIssues{err: fmt.Errorf("issues.List: %v", err)}
+	}
+	if d.FetchBodies {
+		fillBodies(ctx, service, r.Repo, is)
+	}
+	openCount, err := service.Count(ctx, r.Repo, issues.IssueListOptions{State: issues.StateFilter(issues.OpenState)})
+	if err != nil {
+		return repoIssues{err: fmt.Errorf("issues.Count(open): %v", err)}
+	}
+	closedCount, err := service.Count(ctx, r.Repo, issues.IssueListOptions{State: issues.StateFilter(issues.ClosedState)})
+	if err != nil {
+		return repoIssues{err: fmt.Errorf("issues.Count(closed): %v", err)}
+	}
+	var es []component.IssueEntry
+	for _, i := range is {
+		es = append(es, component.IssueEntry{Issue: i, BaseURI: r.BaseURI})
+	}
+	s.RepoSpec = r.Repo
+	es = s.augmentUnread(ctx, es, service, d.Notifications)
+	return repoIssues{entries: es, openCount: openCount, closedCount: closedCount}
+}
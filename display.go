@@ -9,7 +9,7 @@ import (
 
 // issueItem represents an issue item for display purposes.
 type issueItem struct {
-	// IssueItem can be one of issues.Comment, issues.Event.
+	// IssueItem can be one of issues.Comment, issues.Event, crossReferenceItem.
 	IssueItem interface{}
 }
 
@@ -19,6 +19,8 @@ func (i issueItem) TemplateName() string {
 		return "comment"
 	case issues.Event:
 		return "event"
+	case crossReferenceItem:
+		return "cross-reference"
 	default:
 		panic(fmt.Errorf("unknown item type %T", i.IssueItem))
 	}
@@ -30,6 +32,8 @@ func (i issueItem) CreatedAt() time.Time {
 		return i.CreatedAt
 	case issues.Event:
 		return i.CreatedAt
+	case crossReferenceItem:
+		return i.CreatedAt
 	default:
 		panic(fmt.Errorf("unknown item type %T", i))
 	}
@@ -41,6 +45,8 @@ func (i issueItem) ID() uint64 {
 		return i.ID
 	case issues.Event:
 		return i.ID
+	case crossReferenceItem:
+		return i.ID
 	default:
 		panic(fmt.Errorf("unknown item type %T", i))
 	}
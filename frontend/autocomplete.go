@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// completion is a suggestion offered by the autocompletion menu.
+type completion struct {
+	Insert string          // Insert is the text that replaces the query, e.g., "@gopher" or ":tada:".
+	Item   dom.HTMLElement // Item is the menu item to display.
+}
+
+// completionSource provides completions for queries that start with a trigger character.
+type completionSource interface {
+	// Query returns the byte offset in text of a query being typed at its end,
+	// and whether there is one.
+	Query(text string) (start int, ok bool)
+
+	// Complete calls f with completions for query. f may be called asynchronously,
+	// and isn't called if there's an error.
+	Complete(query string, f func([]completion))
+}
+
+// autocomplete is the autocompletion menu of a comment editor, for @mentions and emoji shortcodes.
+type autocomplete struct {
+	editor  *dom.HTMLTextAreaElement
+	sources []completionSource
+	menu    dom.HTMLElement
+
+	start       int    // Byte offset of the query in editor value.
+	query       string // Query being completed, including its trigger character.
+	completions []completion
+	selected    int // Index of the selected completion.
+}
+
+func setupAutocomplete() {
+	var sources []completionSource
+	if !state.DisableUsers {
+		sources = append(sources, mentions{})
+	}
+	sources = append(sources, emojis{})
+
+	for _, e := range document.QuerySelectorAll(".comment-editor") {
+		commentEditor := e.(*dom.HTMLTextAreaElement)
+		a := &autocomplete{editor: commentEditor, sources: sources}
+		commentEditor.AddEventListener("input", false, func(dom.Event) { a.update() })
+		commentEditor.AddEventListener("blur", false, func(dom.Event) { a.hide() })
+		// Handle keys in capture phase on the container, so they get to the menu before
+		// the editor's own keydown handlers (e.g., Tab indentation and Ctrl+Enter).
+		container := getAncestorByClassName(commentEditor, "edit-container")
+		container.AddEventListener("keydown", true, func(event dom.Event) {
+			if event.Target().Underlying() != commentEditor.Underlying() {
+				return
+			}
+			a.keyDown(event.(*dom.KeyboardEvent))
+		})
+	}
+}
+
+// update shows or hides the menu, depending on whether a query is being typed.
+func (a *autocomplete) update() {
+	value, start, end := selection(a.editor)
+	if start != end {
+		a.hide()
+		return
+	}
+	for _, source := range a.sources {
+		queryStart, ok := source.Query(value[:end])
+		if !ok {
+			continue
+		}
+		a.start, a.query = queryStart, value[queryStart:end]
+		query := a.query
+		source.Complete(query, func(completions []completion) {
+			if a.query != query {
+				// User has typed more since.
+				return
+			}
+			a.show(completions)
+		})
+		return
+	}
+	a.hide()
+}
+
+// show displays completions in the menu below the editor.
+// The menu is hidden if there are none.
+func (a *autocomplete) show(completions []completion) {
+	a.completions, a.selected = completions, 0
+	if len(completions) == 0 {
+		a.hide()
+		return
+	}
+	if a.menu == nil {
+		a.menu = document.CreateElement("div").(dom.HTMLElement)
+		a.menu.Class().Add("autocomplete-menu")
+		a.editor.ParentNode().AppendChild(a.menu)
+	}
+	a.menu.SetInnerHTML("")
+	for i, c := range completions {
+		i := i
+		c.Item.Class().Add("autocomplete-menu-item")
+		c.Item.AddEventListener("mousedown", false, func(event dom.Event) {
+			event.PreventDefault() // Keep focus in the editor.
+			a.selected = i
+			a.complete()
+		})
+		a.menu.AppendChild(c.Item)
+	}
+	a.highlight()
+	a.menu.Style().SetProperty("top", fmt.Sprintf("%vpx", a.editor.OffsetTop()+a.editor.OffsetHeight()), "")
+	a.menu.Style().SetProperty("left", fmt.Sprintf("%vpx", a.editor.OffsetLeft()), "")
+	a.menu.Style().SetProperty("display", "block", "")
+}
+
+// hide hides the menu, if it's visible.
+func (a *autocomplete) hide() {
+	a.query, a.completions = "", nil
+	if a.menu != nil {
+		a.menu.Style().SetProperty("display", "none", "")
+	}
+}
+
+// highlight marks the selected completion in the menu.
+func (a *autocomplete) highlight() {
+	for i, c := range a.completions {
+		switch i == a.selected {
+		case true:
+			c.Item.Class().Add("selected")
+		case false:
+			c.Item.Class().Remove("selected")
+		}
+	}
+}
+
+// keyDown handles navigating and choosing from the menu with the keyboard.
+func (a *autocomplete) keyDown(ke *dom.KeyboardEvent) {
+	if len(a.completions) == 0 || ke.CtrlKey || ke.MetaKey || ke.AltKey || ke.ShiftKey {
+		return
+	}
+	switch ke.KeyCode {
+	case 40: // Down.
+		a.selected = (a.selected + 1) % len(a.completions)
+		a.highlight()
+	case 38: // Up.
+		a.selected = (a.selected + len(a.completions) - 1) % len(a.completions)
+		a.highlight()
+	case 13, 9: // Enter, Tab.
+		a.complete()
+	case 27: // Escape.
+		a.hide()
+	default:
+		return
+	}
+	ke.PreventDefault()
+	ke.StopImmediatePropagation()
+}
+
+// complete replaces the query being typed with the selected completion.
+func (a *autocomplete) complete() {
+	insert := a.completions[a.selected].Insert + " "
+	value, _, end := selection(a.editor)
+	value = value[:a.start] + insert + value[end:]
+	caret := a.start + len(insert)
+	a.hide()
+	setSelection(a.editor, value, caret, caret)
+	a.editor.Underlying().Call("dispatchEvent", js.Global.Get("CustomEvent").New("input")) // Trigger "input" event listeners.
+}
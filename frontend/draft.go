@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/shurcooL/go/gopherjs_http/jsutil"
+	"honnef.co/go/js/dom"
+)
+
+// draft is an unsaved comment or issue, autosaved to localStorage
+// so that it survives closing the tab or a failed post.
+type draft struct {
+	Title string `json:",omitempty"` // Title is only used by the new issue editor.
+	Body  string
+}
+
+func setupDrafts() {
+	js.Global.Set("DiscardDraft", jsutil.Wrap(DiscardDraft))
+
+	for _, e := range document.QuerySelectorAll(".comment-editor") {
+		commentEditor := e.(*dom.HTMLTextAreaElement)
+		restoreDraft(commentEditor)
+		commentEditor.AddEventListener("input", false, func(dom.Event) {
+			saveDraft(commentEditor)
+		})
+	}
+	if titleEditor, ok := document.GetElementByID("title-editor").(*dom.HTMLInputElement); ok {
+		commentEditor := document.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
+		titleEditor.AddEventListener("input", false, func(dom.Event) {
+			saveDraft(commentEditor)
+		})
+	}
+}
+
+// draftKey returns the localStorage key for the draft in commentEditor.
+// It's made from the repository, the issue, and which editor it is.
+func draftKey(commentEditor *dom.HTMLTextAreaElement) string {
+	var editor string
+	switch {
+	case commentEditor.HasAttribute("data-id"):
+		editor = "comment-" + commentEditor.GetAttribute("data-id")
+	case state.IssueID == 0:
+		editor = "new-issue"
+		if titleEditor, ok := document.GetElementByID("title-editor").(*dom.HTMLInputElement); ok && titleEditor.HasAttribute("data-template") {
+			editor += "-" + titleEditor.GetAttribute("data-template")
+		}
+	default:
+		editor = "new-comment"
+	}
+	return fmt.Sprintf("issuesapp-draft:%s:%d:%s", state.RepoSpec.URI, state.IssueID, editor)
+}
+
+// loadDraft loads the draft for commentEditor, if there is one.
+func loadDraft(commentEditor *dom.HTMLTextAreaElement) (d draft, ok bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			// localStorage may be unavailable, e.g., due to browser privacy settings.
+			ok = false
+		}
+	}()
+	item := js.Global.Get("localStorage").Call("getItem", draftKey(commentEditor))
+	if item == nil {
+		return draft{}, false
+	}
+	err := json.Unmarshal([]byte(item.String()), &d)
+	return d, err == nil
+}
+
+// saveDraft saves the current contents of commentEditor (and the title editor,
+// if it's a new issue) as a draft. An unchanged editor has no draft.
+func saveDraft(commentEditor *dom.HTMLTextAreaElement) {
+	d := draft{Body: commentEditor.Value}
+	unchanged := d.Body == commentEditor.GetAttribute("data-raw") // Empty, unless editing an existing comment or starting from an issue template.
+	if titleEditor, ok := document.GetElementByID("title-editor").(*dom.HTMLInputElement); ok && state.IssueID == 0 {
+		d.Title = titleEditor.Value
+		unchanged = unchanged && d.Title == titleEditor.GetAttribute("data-raw")
+	}
+	if unchanged {
+		clearDraft(commentEditor)
+		return
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+	func() {
+		defer func() { recover() }() // localStorage may be unavailable or full.
+		js.Global.Get("localStorage").Call("setItem", draftKey(commentEditor), string(b))
+	}()
+	setDiscardDraftVisible(commentEditor, true)
+}
+
+// clearDraft removes the draft for commentEditor, if any.
+// It's called once the comment or issue was successfully posted.
+func clearDraft(commentEditor *dom.HTMLTextAreaElement) {
+	func() {
+		defer func() { recover() }() // localStorage may be unavailable.
+		js.Global.Get("localStorage").Call("removeItem", draftKey(commentEditor))
+	}()
+	setDiscardDraftVisible(commentEditor, false)
+}
+
+// restoreDraft restores the draft for commentEditor, if there is one.
+// A draft of a comment edit reopens the edit view.
+func restoreDraft(commentEditor *dom.HTMLTextAreaElement) {
+	d, ok := loadDraft(commentEditor)
+	if !ok {
+		return
+	}
+	commentEditor.Value = d.Body
+	if titleEditor, ok := document.GetElementByID("title-editor").(*dom.HTMLInputElement); ok && state.IssueID == 0 {
+		titleEditor.Value = d.Title
+		titleEditor.Underlying().Call("dispatchEvent", js.Global.Get("CustomEvent").New("input")) // Trigger "input" event listeners.
+	}
+	if commentEditor.HasAttribute("data-id") {
+		commentView, editView := editViews(getAncestorByClassName(commentEditor, "comment-edit-container"))
+		commentView.Style().SetProperty("display", "none", "")
+		editView.Style().SetProperty("display", "block", "")
+	}
+	commentEditor.Underlying().Call("dispatchEvent", js.Global.Get("CustomEvent").New("input")) // Trigger "input" event listeners.
+	setDiscardDraftVisible(commentEditor, true)
+}
+
+// DiscardDraft discards the draft in the editor containing this,
+// resetting the editor to its initial contents.
+func DiscardDraft(this dom.HTMLElement) {
+	container := getAncestorByClassName(this, "edit-container")
+	commentEditor := container.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
+
+	if !dom.GetWindow().Confirm("Are you sure you want to discard your draft?") {
+		return
+	}
+
+	clearDraft(commentEditor)
+	commentEditor.Value = commentEditor.GetAttribute("data-raw") // Empty, unless editing an existing comment or starting from an issue template.
+	if titleEditor, ok := document.GetElementByID("title-editor").(*dom.HTMLInputElement); ok && state.IssueID == 0 {
+		titleEditor.Value = titleEditor.GetAttribute("data-raw")
+		titleEditor.Underlying().Call("dispatchEvent", js.Global.Get("CustomEvent").New("input")) // Trigger "input" event listeners.
+	}
+	commentEditor.Underlying().Call("dispatchEvent", js.Global.Get("CustomEvent").New("input")) // Trigger "input" event listeners.
+	switchWriteTab(container, commentEditor)
+}
+
+// setDiscardDraftVisible shows or hides the "Discard draft" link of commentEditor.
+func setDiscardDraftVisible(commentEditor *dom.HTMLTextAreaElement, visible bool) {
+	discardDraft, ok := getAncestorByClassName(commentEditor, "edit-container").QuerySelector(".discard-draft").(dom.HTMLElement)
+	if !ok {
+		return
+	}
+	switch visible {
+	case true:
+		discardDraft.Style().SetProperty("display", "inline", "")
+	case false:
+		discardDraft.Style().SetProperty("display", "none", "")
+	}
+}
@@ -1,14 +1,14 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"strconv"
 
-	"github.com/shurcooL/github_flavored_markdown"
 	"github.com/shurcooL/issues"
-	"github.com/shurcooL/markdownfmt/markdown"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/issuesapp/httpclient"
 	"honnef.co/go/js/dom"
 )
 
@@ -18,14 +18,16 @@ func (f *frontend) EditComment(action string, this dom.HTMLElement, evt dom.Even
 	}
 
 	container := getAncestorByClassName(this, "comment-edit-container")
-	// HACK: Currently the child nodes are [text, div, text, div, text], but that isn't reliable.
-	commentView := container.ChildNodes()[1].(dom.HTMLElement)
-	editView := container.ChildNodes()[3].(dom.HTMLElement)
+	commentView, editView := editViews(container)
 	commentEditor := editView.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
 
 	switch action {
 	case "edit":
 		commentEditor.Value = commentEditor.GetAttribute("data-raw")
+		if d, ok := loadDraft(commentEditor); ok {
+			commentEditor.Value = d.Body
+		}
+		hideConflict(editView)
 
 		commentView.Style().SetProperty("display", "none", "")
 		editView.Style().SetProperty("display", "block", "")
@@ -40,14 +42,15 @@ func (f *frontend) EditComment(action string, this dom.HTMLElement, evt dom.Even
 				}
 			}
 			commentEditor.Value = commentEditor.GetAttribute("data-raw")
+			clearDraft(commentEditor)
+			hideConflict(editView)
 		case "update":
 			if commentEditor.Value != commentEditor.GetAttribute("data-raw") {
-				fmted, _ := markdown.Process("", []byte(commentEditor.Value), nil)
-				fmted = bytes.TrimSpace(fmted)
+				fmted := formatBody(commentEditor.Value)
 				if len(fmted) == 0 {
 					// Empty body isn't allowed.
 					// TODO: Unless it's an issue description (initial comment).
-					// TODO: Display error? Disable "Update comment" button?
+					showError("Updating comment", errors.New("comment body can't be blank"))
 					return
 				}
 				commentID, err := strconv.ParseUint(commentEditor.GetAttribute("data-id"), 10, 64)
@@ -55,22 +58,40 @@ func (f *frontend) EditComment(action string, this dom.HTMLElement, evt dom.Even
 					panic(err)
 				}
 
+				// The edit is based on the body the user started editing from.
+				prevRaw := commentEditor.GetAttribute("data-raw")
+				baseVersion := common.BodyVersion(prevRaw)
+				markdownBody := commentView.QuerySelector(".markdown-body").(*dom.HTMLDivElement)
+				prevHTML := markdownBody.InnerHTML()
+				body := string(fmted)
 				go func() {
-					body := string(fmted)
 					cr := issues.CommentRequest{
 						ID:   commentID,
 						Body: &body,
 					}
-					_, err := f.is.EditComment(context.Background(), state.RepoSpec, state.IssueID, cr)
-					if err != nil {
-						// TODO: Handle failure more visibly in the UI.
-						log.Println("EditComment:", err)
+					_, err := f.editComment(context.Background(), cr, baseVersion)
+					switch {
+					case err == nil:
+						clearDraft(commentEditor)
+					case err == httpclient.ErrConflict:
+						f.showConflict(commentView, editView, commentEditor, commentID, body)
+					case err != nil:
+						// Roll back the optimistic update, and let the user try again
+						// without losing their text.
+						commentEditor.SetAttribute("data-raw", prevRaw)
+						markdownBody.SetInnerHTML(prevHTML)
+						commentEditor.Value = body
+						commentView.Style().SetProperty("display", "none", "")
+						editView.Style().SetProperty("display", "block", "")
+						showError("Updating comment", err)
 					}
 				}()
 
-				commentEditor.SetAttribute("data-raw", string(fmted))
-				markdownBody := commentView.QuerySelector(".markdown-body").(*dom.HTMLDivElement)
-				markdownBody.SetInnerHTML(string(github_flavored_markdown.Markdown(fmted)))
+				// Optimistically show the updated comment.
+				commentEditor.SetAttribute("data-raw", body)
+				renderEditableComment(markdownBody, commentEditor, body)
+				hideConflict(editView)
+				hideNotification()
 			}
 		}
 
@@ -83,6 +104,62 @@ func (f *frontend) EditComment(action string, this dom.HTMLElement, evt dom.Even
 	}
 }
 
+// editComment edits a comment of the current issue. If the issues service supports it,
+// the edit is rejected with httpclient.ErrConflict unless the comment body
+// still has baseVersion.
+func (f *frontend) editComment(ctx context.Context, cr issues.CommentRequest, baseVersion string) (issues.Comment, error) {
+	is, ok := f.is.(interface {
+		EditCommentIfVersion(ctx context.Context, repo issues.RepoSpec, id uint64, cr issues.CommentRequest, baseVersion string) (issues.Comment, error)
+	})
+	if !ok {
+		return f.is.EditComment(ctx, state.RepoSpec, state.IssueID, cr)
+	}
+	return is.EditCommentIfVersion(ctx, state.RepoSpec, state.IssueID, cr, baseVersion)
+}
+
+// showConflict is called when an edit was rejected because someone else edited
+// the comment in the meantime. It shows the comment with their version, and
+// reopens the edit view with the user's version in the editor, so no text is lost.
+// Updating the comment again replaces their version.
+func (f *frontend) showConflict(commentView, editView dom.HTMLElement, commentEditor *dom.HTMLTextAreaElement, commentID uint64, yours string) {
+	cs, err := f.is.ListComments(context.Background(), state.RepoSpec, state.IssueID, nil)
+	if err != nil {
+		showError("Loading the edited comment", err)
+		return
+	}
+	var theirs *issues.Comment
+	for i := range cs {
+		if cs[i].ID == commentID {
+			theirs = &cs[i]
+			break
+		}
+	}
+	if theirs == nil {
+		showError("Loading the edited comment", fmt.Errorf("comment %d not found", commentID))
+		return
+	}
+
+	commentEditor.SetAttribute("data-raw", theirs.Body)
+	markdownBody := commentView.QuerySelector(".markdown-body").(*dom.HTMLDivElement)
+	renderEditableComment(markdownBody, commentEditor, theirs.Body)
+	commentEditor.Value = yours
+
+	editView.QuerySelector(".comment-conflict-theirs").SetTextContent(theirs.Body)
+	editView.QuerySelector(".comment-conflict").(dom.HTMLElement).Style().SetProperty("display", "block", "")
+	commentView.Style().SetProperty("display", "none", "")
+	editView.Style().SetProperty("display", "block", "")
+}
+
+func hideConflict(editView dom.HTMLElement) {
+	editView.QuerySelector(".comment-conflict").(dom.HTMLElement).Style().SetProperty("display", "none", "")
+}
+
+// editViews returns the comment view and the edit view of a comment-edit-container.
+func editViews(container dom.Element) (commentView, editView dom.HTMLElement) {
+	// HACK: Currently the child nodes are [text, div, text, div, text], but that isn't reliable.
+	return container.ChildNodes()[1].(dom.HTMLElement), container.ChildNodes()[3].(dom.HTMLElement)
+}
+
 func getAncestorByClassName(el dom.Element, class string) dom.Element {
 	for ; el != nil && !el.Class().Contains(class); el = el.ParentElement() {
 	}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/reactions"
+	"honnef.co/go/js/dom"
+)
+
+// emojiQuery matches an emoji shortcode being typed at the end of text.
+// At least 2 characters need to follow the ':', so that typing
+// ordinary punctuation doesn't open the menu. The submatch is the query.
+var emojiQuery = regexp.MustCompile(`(?:^|[\s(])(:[a-z0-9_+-]{2,})$`)
+
+// emojiCompletionLimit is the maximum number of emoji completions offered.
+const emojiCompletionLimit = 10
+
+// emojis is a completion source of emoji shortcodes, like ":tada:".
+type emojis struct{}
+
+func (emojis) Query(text string) (start int, ok bool) {
+	m := emojiQuery.FindStringSubmatchIndex(text)
+	if m == nil {
+		return 0, false
+	}
+	return m[2], true
+}
+
+// Complete offers shortcodes that start with query first,
+// followed by those that contain it elsewhere.
+func (emojis) Complete(query string, f func([]completion)) {
+	var prefixed, contained []string
+	for _, shortcode := range reactions.Sorted {
+		switch {
+		case strings.HasPrefix(shortcode, query):
+			prefixed = append(prefixed, shortcode)
+		case strings.Contains(shortcode, query[len(":"):]):
+			contained = append(contained, shortcode)
+		}
+	}
+	var cs []completion
+	for _, shortcode := range append(prefixed, contained...) {
+		if len(cs) == emojiCompletionLimit {
+			break
+		}
+		item := document.CreateElement("div").(dom.HTMLElement)
+		item.SetInnerHTML(string(common.RenderEmojis([]byte(shortcode))))
+		name := document.CreateElement("span").(dom.HTMLElement)
+		name.SetTextContent(shortcode)
+		item.AppendChild(name)
+		cs = append(cs, completion{Insert: shortcode, Item: item})
+	}
+	f(cs)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// MarkdownFormat applies Markdown formatting to the selection in the comment editor
+// whose toolbar contains this. format is one of "bold", "italic", "code", "link",
+// "quote", "bulleted-list", "numbered-list", "task-list", "heading".
+func MarkdownFormat(this dom.HTMLElement, format string) {
+	container := getAncestorByClassName(this, "edit-container")
+	commentEditor := container.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
+	switchWriteTab(container, commentEditor)
+	applyFormat(commentEditor, format)
+}
+
+// MarkdownKeyDownHandler handles keyboard shortcuts in a comment editor:
+// Ctrl/Cmd+B, Ctrl/Cmd+I and Ctrl/Cmd+K for bold, italic and link formatting,
+// and Ctrl/Cmd+Enter to submit.
+func (f *frontend) MarkdownKeyDownHandler(this dom.HTMLElement, event dom.Event) {
+	ke := event.(*dom.KeyboardEvent)
+	if !(ke.CtrlKey || ke.MetaKey) || ke.AltKey || ke.ShiftKey || ke.Repeat {
+		return
+	}
+	commentEditor := this.(*dom.HTMLTextAreaElement)
+
+	switch ke.KeyCode {
+	case 'B':
+		applyFormat(commentEditor, "bold")
+	case 'I':
+		applyFormat(commentEditor, "italic")
+	case 'K':
+		applyFormat(commentEditor, "link")
+	case 13: // Enter.
+		switch {
+		case commentEditor.HasAttribute("data-id"):
+			// Don't prevent default here, EditComment ignores events that had default prevented.
+			f.EditComment("update", commentEditor, event)
+		case getAncestorByClassName(commentEditor, "edit-container").ID() == "new-comment-container":
+			PostComment()
+		default:
+			if createIssueButton, ok := document.GetElementByID("create-issue-button").(dom.HTMLElement); ok && !createIssueButton.HasAttribute("disabled") {
+				CreateNewIssue()
+			}
+		}
+	default:
+		return
+	}
+	ke.PreventDefault()
+}
+
+// applyFormat applies Markdown formatting to the selection in t.
+func applyFormat(t *dom.HTMLTextAreaElement, format string) {
+	value, start, end := selection(t)
+	switch format {
+	case "bold":
+		value, start, end = wrapSelection(value, start, end, "**", "**")
+	case "italic":
+		value, start, end = wrapSelection(value, start, end, "_", "_")
+	case "code":
+		if strings.Contains(value[start:end], "\n") {
+			value, start, end = wrapSelection(value, start, end, "```\n", "\n```")
+		} else {
+			value, start, end = wrapSelection(value, start, end, "`", "`")
+		}
+	case "link":
+		// Select the URL placeholder, so it can be typed over.
+		text := value[start:end]
+		value = value[:start] + "[" + text + "](url)" + value[end:]
+		start, end = start+len("["+text+"]("), start+len("["+text+"](url")
+	case "quote":
+		value, start, end = prefixLines(value, start, end, func(int) string { return "> " })
+	case "bulleted-list":
+		value, start, end = prefixLines(value, start, end, func(int) string { return "- " })
+	case "numbered-list":
+		value, start, end = prefixLines(value, start, end, func(i int) string { return strconv.Itoa(i+1) + ". " })
+	case "task-list":
+		value, start, end = prefixLines(value, start, end, func(int) string { return "- [ ] " })
+	case "heading":
+		value, start, end = prefixLines(value, start, end, func(int) string { return "### " })
+	default:
+		return
+	}
+	setSelection(t, value, start, end)
+	t.Focus()
+	t.Underlying().Call("dispatchEvent", js.Global.Get("CustomEvent").New("input")) // Trigger "input" event listeners.
+}
+
+// wrapSelection wraps value[start:end] with prefix and suffix, or unwraps it
+// if it's already wrapped. It returns the new value and selection.
+func wrapSelection(value string, start, end int, prefix, suffix string) (string, int, int) {
+	if strings.HasSuffix(value[:start], prefix) && strings.HasPrefix(value[end:], suffix) {
+		value = value[:start-len(prefix)] + value[start:end] + value[end+len(suffix):]
+		return value, start - len(prefix), end - len(prefix)
+	}
+	value = value[:start] + prefix + value[start:end] + suffix + value[end:]
+	return value, start + len(prefix), end + len(prefix)
+}
+
+// prefixLines prefixes each line touched by the selection value[start:end]
+// with prefix(i), where i is the index of the line within the selection.
+// It returns the new value and selection, which covers the prefixed lines.
+func prefixLines(value string, start, end int, prefix func(i int) string) (string, int, int) {
+	lineStart := strings.LastIndex(value[:start], "\n") + 1
+	lineEnd := len(value)
+	if i := strings.Index(value[end:], "\n"); i != -1 {
+		lineEnd = end + i
+	}
+	lines := strings.Split(value[lineStart:lineEnd], "\n")
+	for i := range lines {
+		lines[i] = prefix(i) + lines[i]
+	}
+	prefixed := strings.Join(lines, "\n")
+	return value[:lineStart] + prefixed + value[lineEnd:], lineStart, lineStart + len(prefixed)
+}
+
+// selection returns the value of t and its selection as byte offsets into value.
+// The DOM reports selection offsets in UTF-16 code units.
+func selection(t *dom.HTMLTextAreaElement) (value string, start, end int) {
+	value = t.Value
+	return value, byteOffset(value, t.SelectionStart), byteOffset(value, t.SelectionEnd)
+}
+
+// setSelection sets the value of t and its selection, given as byte offsets into value.
+func setSelection(t *dom.HTMLTextAreaElement, value string, start, end int) {
+	t.Value = value
+	t.SelectionStart, t.SelectionEnd = utf16Offset(value, start), utf16Offset(value, end)
+}
+
+// byteOffset converts an offset in UTF-16 code units into a byte offset in s.
+func byteOffset(s string, utf16Offset int) int {
+	var n int
+	for i, r := range s {
+		if n >= utf16Offset {
+			return i
+		}
+		n += utf16Len(r)
+	}
+	return len(s)
+}
+
+// utf16Offset converts a byte offset in s into an offset in UTF-16 code units.
+func utf16Offset(s string, byteOffset int) int {
+	var n int
+	for _, r := range s[:byteOffset] {
+		n += utf16Len(r)
+	}
+	return n
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
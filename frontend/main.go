@@ -2,28 +2,32 @@
 //
 // It's a Go package meant to be compiled with GOARCH=js
 // and executed in a browser, where the DOM is available.
+//
+// Built with the serverpreview build tag, it leaves out the in-browser Markdown
+// renderer and formatter, and has Markdown rendered by the server instead.
+// That script is smaller, and needs an app that serves "/markdown",
+// e.g., with issuesapp.Options.ServerPreview set.
 package main
 
 import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/gopherjs/gopherjs/js"
 	"github.com/shurcooL/frontend/reactionsmenu"
 	"github.com/shurcooL/frontend/tabsupport"
-	"github.com/shurcooL/github_flavored_markdown"
 	"github.com/shurcooL/go/gopherjs_http/jsutil"
 	"github.com/shurcooL/issues"
 	"github.com/shurcooL/issuesapp/common"
 	"github.com/shurcooL/issuesapp/httpclient"
-	"github.com/shurcooL/markdownfmt/markdown"
 	"golang.org/x/oauth2"
 	"honnef.co/go/js/dom"
 )
@@ -51,6 +55,8 @@ func main() {
 	js.Global.Set("PostComment", PostComment)
 	js.Global.Set("EditComment", jsutil.Wrap(f.EditComment))
 	js.Global.Set("TabSupportKeyDownHandler", jsutil.Wrap(tabsupport.KeyDownHandler))
+	js.Global.Set("MarkdownFormat", jsutil.Wrap(MarkdownFormat))
+	js.Global.Set("MarkdownKeyDownHandler", jsutil.Wrap(f.MarkdownKeyDownHandler))
 
 	switch readyState := document.ReadyState(); readyState {
 	case "loading":
@@ -79,6 +85,10 @@ func setup(f *frontend) {
 		})
 	}
 
+	setupDrafts()
+	setupAutocomplete()
+	f.setupTasks()
+
 	if !state.DisableReactions {
 		reactionsService := IssuesReactions{Issues: f.is}
 		reactionsmenu.Setup(state.RepoSpec.URI, reactionsService, state.CurrentUser)
@@ -132,116 +142,147 @@ func postJSON(url string, v interface{}) (*http.Response, error) {
 func CreateNewIssue() {
 	titleEditor := document.GetElementByID("title-editor").(*dom.HTMLInputElement)
 	commentEditor := document.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
+	createIssueButton := document.GetElementByID("create-issue-button").(dom.HTMLElement)
 
 	title := strings.TrimSpace(titleEditor.Value)
 	if title == "" {
-		log.Println("cannot create issue with empty title")
+		showError("Creating issue", errors.New("title can't be blank"))
 		return
 	}
-	fmted, _ := markdown.Process("", []byte(commentEditor.Value), nil)
 	newIssue := issues.Issue{
 		Title: title,
 		Comment: issues.Comment{
-			Body: string(bytes.TrimSpace(fmted)),
+			Body: string(formatBody(commentEditor.Value)),
 		},
 	}
+	// Labels of the issue template are applied by the server,
+	// so only the other checked labels are sent.
+	for _, e := range document.QuerySelectorAll(".label-selector input:checked:not(:disabled)") {
+		newIssue.Labels = append(newIssue.Labels, issues.Label{Name: e.(*dom.HTMLInputElement).Value})
+	}
+
+	// Prevent creating the issue twice while the request is in flight.
+	createIssueButton.SetAttribute("disabled", "disabled")
+	hideNotification()
 
 	go func() {
-		resp, err := postJSON("new", newIssue)
-		if err != nil {
-			log.Println(err)
-			return
-		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
+		location, err := createNewIssue(newIssue, titleEditor.GetAttribute("data-template"))
 		if err != nil {
-			log.Println(err)
+			createIssueButton.RemoveAttribute("disabled")
+			showError("Creating issue", err)
 			return
 		}
-
-		fmt.Printf("got reply: %v\n%q\n", resp.Status, string(body))
-
-		switch resp.StatusCode {
-		case http.StatusOK:
-			// Redirect.
-			dom.GetWindow().Location().Href = string(body)
-		}
+		clearDraft(commentEditor)
+		// Redirect.
+		dom.GetWindow().Location().Href = location
 	}()
 }
 
+// createNewIssue creates the issue via the app, returning the URL of the new issue.
+// If template is not empty, the issue gets the labels of that issue template.
+func createNewIssue(newIssue issues.Issue, template string) (location string, err error) {
+	u := "new"
+	if template != "" {
+		u += "?" + url.Values{"template": {template}}.Encode()
+	}
+	resp, err := postJSON(u, newIssue)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp, body)
+	}
+	return string(body), nil
+}
+
 func ToggleIssueState(issueState issues.State) {
 	go func() {
+		hideNotification()
+
 		// Post comment first if there's text entered, and we're closing.
 		if strings.TrimSpace(document.QuerySelector("#new-comment-container .comment-editor").(*dom.HTMLTextAreaElement).Value) != "" &&
 			issueState == issues.ClosedState {
 			err := postComment()
 			if err != nil {
-				log.Println(err)
+				showError("Posting comment", err)
 				return
 			}
 		}
 
-		ir := issues.IssueRequest{
-			State: &issueState,
-		}
-		value, err := json.Marshal(ir)
-		if err != nil {
-			panic(err)
-		}
-
-		resp, err := http.PostForm(state.BaseURI+state.ReqPath+"/edit", url.Values{"value": {string(value)}})
+		err := toggleIssueState(issueState)
 		if err != nil {
-			log.Println(err)
-			return
-		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Println(err)
+			showError("Changing issue state", err)
 			return
 		}
 
-		data, err := url.ParseQuery(string(body))
-		if err != nil {
-			log.Println(err)
-			return
-		}
-
-		switch resp.StatusCode {
-		case http.StatusOK:
-			issueStateBadge := document.GetElementByID("issue-state-badge")
-			issueStateBadge.SetInnerHTML(data.Get("issue-state-badge"))
-
-			issueToggleButton := document.GetElementByID("issue-toggle-button")
-			issueToggleButton.SetOuterHTML(data.Get("issue-toggle-button"))
-			setupIssueToggleButton()
-
-			for _, newEventData := range data["new-event"] {
-				// Create event.
-				newEvent := document.CreateElement("div").(*dom.HTMLDivElement)
-				newItemMarker := document.GetElementByID("new-item-marker")
-				newItemMarker.ParentNode().InsertBefore(newEvent, newItemMarker)
-				newEvent.SetOuterHTML(newEventData)
-			}
-		}
-
 		// Post comment after if there's text entered, and we're reopening.
 		if strings.TrimSpace(document.QuerySelector("#new-comment-container .comment-editor").(*dom.HTMLTextAreaElement).Value) != "" &&
 			issueState == issues.OpenState {
 			err := postComment()
 			if err != nil {
-				log.Println(err)
+				showError("Posting comment", err)
 				return
 			}
 		}
 	}()
 }
 
+// toggleIssueState changes the issue state via the app, and updates the page to reflect it.
+func toggleIssueState(issueState issues.State) error {
+	ir := issues.IssueRequest{
+		State: &issueState,
+	}
+	value, err := json.Marshal(ir)
+	if err != nil {
+		panic(err)
+	}
+
+	resp, err := http.PostForm(state.BaseURI+state.ReqPath+"/edit", url.Values{"value": {string(value)}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp, body)
+	}
+
+	data, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+
+	issueStateBadge := document.GetElementByID("issue-state-badge")
+	issueStateBadge.SetInnerHTML(data.Get("issue-state-badge"))
+
+	issueToggleButton := document.GetElementByID("issue-toggle-button")
+	issueToggleButton.SetOuterHTML(data.Get("issue-toggle-button"))
+	setupIssueToggleButton()
+
+	for _, newEventData := range data["new-event"] {
+		// Create event.
+		newEvent := document.CreateElement("div").(*dom.HTMLDivElement)
+		newItemMarker := document.GetElementByID("new-item-marker")
+		newItemMarker.ParentNode().InsertBefore(newEvent, newItemMarker)
+		newEvent.SetOuterHTML(newEventData)
+	}
+	return nil
+}
+
 func PostComment() {
 	go func() {
+		hideNotification()
 		err := postComment()
 		if err != nil {
-			log.Println(err)
+			showError("Posting comment", err)
 		}
 	}()
 }
@@ -250,11 +291,10 @@ func PostComment() {
 func postComment() error {
 	commentEditor := document.QuerySelector("#new-comment-container .comment-editor").(*dom.HTMLTextAreaElement)
 
-	fmted, _ := markdown.Process("", []byte(commentEditor.Value), nil)
-	if len(fmted) == 0 {
+	value := string(formatBody(commentEditor.Value))
+	if len(value) == 0 {
 		return fmt.Errorf("cannot post empty comment")
 	}
-	value := string(bytes.TrimSpace(fmted))
 
 	resp, err := http.PostForm(state.BaseURI+state.ReqPath+"/comment", url.Values{"value": {value}})
 	if err != nil {
@@ -266,8 +306,6 @@ func postComment() error {
 		return err
 	}
 
-	fmt.Printf("got reply: %v\n", resp.Status)
-
 	switch resp.StatusCode {
 	case http.StatusOK:
 		// Create comment.
@@ -279,13 +317,14 @@ func postComment() error {
 		newComment.SetOuterHTML(string(body))
 
 		// Reset new-comment component.
+		clearDraft(commentEditor)
 		commentEditor.Value = ""
 		commentEditor.Underlying().Call("dispatchEvent", js.Global.Get("CustomEvent").New("input")) // Trigger "input" event listeners.
 		switchWriteTab(document.GetElementByID("new-comment-container"), commentEditor)
 
 		return nil
 	default:
-		return fmt.Errorf("did not get acceptable status code: %v", resp.Status)
+		return responseError(resp, body)
 	}
 }
 
@@ -299,13 +338,24 @@ func MarkdownPreview(this dom.HTMLElement) {
 	commentEditor := container.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
 	commentPreview := container.QuerySelector(".comment-preview").(*dom.HTMLDivElement)
 
-	fmted, _ := markdown.Process("", []byte(commentEditor.Value), nil)
-	value := bytes.TrimSpace(fmted)
+	value := formatBody(commentEditor.Value)
 
-	if len(value) != 0 {
-		commentPreview.SetInnerHTML(string(github_flavored_markdown.Markdown(value)))
-	} else {
+	switch {
+	case len(value) == 0:
 		commentPreview.SetInnerHTML(`<i class="gray">Nothing to preview.</i>`)
+	case serverRendered():
+		commentPreview.SetInnerHTML(`<i class="gray">Loading preview...</i>`)
+		go func() {
+			rendered, err := renderPreview(string(value))
+			if err != nil {
+				commentPreview.SetInnerHTML(`<i class="gray">Preview failed to load.</i>`)
+				showError("Loading preview", err)
+				return
+			}
+			commentPreview.SetInnerHTML(rendered)
+		}()
+	default:
+		commentPreview.SetInnerHTML(string(renderMarkdown(value)))
 	}
 
 	container.QuerySelector(".write-tab-link").(dom.Element).Class().Remove("active")
@@ -314,6 +364,27 @@ func MarkdownPreview(this dom.HTMLElement) {
 	commentPreview.Style().SetProperty("display", "block", "")
 }
 
+// renderPreview renders Markdown body on the server, the same way comments are rendered.
+func renderPreview(body string) (string, error) {
+	form := url.Values{"body": {body}}
+	if state.IssueID != 0 {
+		form.Set("issue", strconv.FormatUint(state.IssueID, 10))
+	}
+	resp, err := http.PostForm(state.BaseURI+"/markdown", form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	rendered, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp, rendered)
+	}
+	return string(rendered), nil
+}
+
 func SwitchWriteTab(this dom.HTMLElement) {
 	container := getAncestorByClassName(this, "edit-container")
 	commentEditor := container.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
@@ -0,0 +1,28 @@
+package main
+
+import "bytes"
+
+// renderMarkdown renders a Markdown body in the browser, the same way the server
+// does by default. It's nil if the script is built with the serverpreview build tag,
+// which leaves the renderer out. Then Markdown is always rendered by the server.
+var renderMarkdown func(body []byte) []byte
+
+// formatMarkdown formats a Markdown body before it's previewed or submitted.
+// It's nil if the script is built with the serverpreview build tag,
+// in which case bodies are submitted as written.
+var formatMarkdown func(body []byte) []byte
+
+// serverRendered reports whether Markdown is rendered by the server.
+func serverRendered() bool {
+	return state.ServerPreview || renderMarkdown == nil
+}
+
+// formatBody returns the Markdown body formatted with formatMarkdown, if it's set,
+// with leading and trailing white space removed.
+func formatBody(body string) []byte {
+	b := []byte(body)
+	if formatMarkdown != nil {
+		b = formatMarkdown(b)
+	}
+	return bytes.TrimSpace(b)
+}
@@ -0,0 +1,20 @@
+//go:build !serverpreview
+// +build !serverpreview
+
+package main
+
+import (
+	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/markdownfmt/markdown"
+)
+
+func init() {
+	renderMarkdown = func(body []byte) []byte {
+		return common.RenderEmojis(github_flavored_markdown.Markdown(body))
+	}
+	formatMarkdown = func(body []byte) []byte {
+		fmted, _ := markdown.Process("", body, nil)
+		return fmted
+	}
+}
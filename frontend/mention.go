@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"honnef.co/go/js/dom"
+)
+
+// mentionSuggestion is a user suggested for @mention autocompletion,
+// as served by the app's "/mentions" endpoint.
+type mentionSuggestion struct {
+	Login     string
+	Name      string
+	AvatarURL string
+}
+
+// mentionQuery matches an @mention being typed at the end of text.
+// The submatch is the query, including the '@'.
+var mentionQuery = regexp.MustCompile(`(?:^|[^\w@./])(@(?:[A-Za-z0-9][A-Za-z0-9-]*)?)$`)
+
+// mentionCache caches suggestions by login prefix, so they're fetched once per page.
+var mentionCache = make(map[string][]mentionSuggestion)
+
+// mentions is a completion source of @mentions of users.
+type mentions struct{}
+
+func (mentions) Query(text string) (start int, ok bool) {
+	m := mentionQuery.FindStringSubmatchIndex(text)
+	if m == nil {
+		return 0, false
+	}
+	return m[2], true
+}
+
+func (mentions) Complete(query string, f func([]completion)) {
+	prefix := query[len("@"):]
+	if suggestions, ok := mentionCache[prefix]; ok {
+		f(mentionCompletions(suggestions))
+		return
+	}
+	go func() {
+		suggestions, err := fetchMentions(prefix)
+		if err != nil {
+			// Autocompletion is a convenience, so don't bother the user with errors.
+			return
+		}
+		mentionCache[prefix] = suggestions
+		f(mentionCompletions(suggestions))
+	}()
+}
+
+// fetchMentions fetches users suggested for @mention autocompletion of prefix.
+func fetchMentions(prefix string) ([]mentionSuggestion, error) {
+	q := url.Values{"q": {prefix}}
+	if state.IssueID != 0 {
+		q.Set("issue", strconv.FormatUint(state.IssueID, 10))
+	}
+	resp, err := http.Get(state.BaseURI + "/mentions?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp, body)
+	}
+	var suggestions []mentionSuggestion
+	err = json.Unmarshal(body, &suggestions)
+	return suggestions, err
+}
+
+func mentionCompletions(suggestions []mentionSuggestion) []completion {
+	var cs []completion
+	for _, s := range suggestions {
+		item := document.CreateElement("div").(dom.HTMLElement)
+		if s.AvatarURL != "" {
+			avatar := document.CreateElement("img").(dom.HTMLElement)
+			avatar.Class().Add("avatar")
+			avatar.SetAttribute("src", s.AvatarURL)
+			item.AppendChild(avatar)
+		}
+		login := document.CreateElement("strong").(dom.HTMLElement)
+		login.SetTextContent(s.Login)
+		item.AppendChild(login)
+		if s.Name != "" {
+			name := document.CreateElement("span").(dom.HTMLElement)
+			name.Class().Add("gray")
+			name.SetTextContent(s.Name)
+			item.AppendChild(name)
+		}
+		cs = append(cs, completion{Insert: "@" + s.Login, Item: item})
+	}
+	return cs
+}
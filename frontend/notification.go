@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"honnef.co/go/js/dom"
+)
+
+// showError displays err to the user in a notification banner at the top of the page,
+// replacing any previous notification. The banner stays until dismissed.
+// action describes what failed, e.g., "Posting comment".
+func showError(action string, err error) {
+	banner, ok := document.GetElementByID("notification-banner").(dom.HTMLElement)
+	if !ok {
+		banner = document.CreateElement("div").(dom.HTMLElement)
+		banner.SetID("notification-banner")
+		document.Body().InsertBefore(banner, document.Body().FirstChild())
+	}
+	banner.SetInnerHTML("")
+
+	message := document.CreateElement("span").(dom.HTMLElement)
+	message.Class().Add("notification-message")
+	message.SetTextContent(fmt.Sprintf("%s failed: %v", action, err))
+	banner.AppendChild(message)
+
+	dismiss := document.CreateElement("a").(dom.HTMLElement)
+	dismiss.Class().Add("notification-dismiss")
+	dismiss.SetAttribute("href", "javascript:")
+	dismiss.SetAttribute("title", "Dismiss")
+	dismiss.SetTextContent("×")
+	dismiss.AddEventListener("click", false, func(dom.Event) { hideNotification() })
+	banner.AppendChild(dismiss)
+
+	banner.Style().SetProperty("display", "flex", "")
+}
+
+// hideNotification hides the notification banner, if it's visible.
+func hideNotification() {
+	if banner, ok := document.GetElementByID("notification-banner").(dom.HTMLElement); ok {
+		banner.Style().SetProperty("display", "none", "")
+	}
+}
+
+// responseError returns an error for an unsuccessful response, using the server's
+// error message from body, if any, so that it can be shown to the user.
+func responseError(resp *http.Response, body []byte) error {
+	if message := strings.TrimSpace(string(body)); message != "" {
+		return errors.New(message)
+	}
+	return fmt.Errorf("did not get acceptable status code: %v", resp.Status)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/issuesapp/httpclient"
+	"honnef.co/go/js/dom"
+)
+
+// setupTasks makes toggling a task list checkbox in a comment that the user
+// can edit save the change. Listening on document covers comments posted later.
+func (f *frontend) setupTasks() {
+	document.AddEventListener("change", false, func(event dom.Event) {
+		checkbox, ok := event.Target().(*dom.HTMLInputElement)
+		if !ok || !checkbox.Class().Contains("task-list-item-checkbox") {
+			return
+		}
+		f.toggleTask(checkbox)
+	})
+}
+
+// toggleTask saves the state of checkbox, which was just toggled,
+// by rewriting the matching task in the comment's Markdown body.
+func (f *frontend) toggleTask(checkbox *dom.HTMLInputElement) {
+	container := getAncestorByClassName(checkbox, "comment-edit-container")
+	commentView, editView := editViews(container)
+	commentEditor := editView.QuerySelector(".comment-editor").(*dom.HTMLTextAreaElement)
+	checkboxes := commentView.QuerySelector(".markdown-body").QuerySelectorAll("input.task-list-item-checkbox")
+
+	index := -1
+	for i, e := range checkboxes {
+		if e.Underlying() == checkbox.Underlying() {
+			index = i
+			break
+		}
+	}
+	checked := checkbox.Checked
+	prevRaw := commentEditor.GetAttribute("data-raw")
+	body, ok := common.SetTask(prevRaw, index, checked)
+	if !ok {
+		checkbox.Checked = !checked
+		showError("Updating task", errors.New("task not found in comment body"))
+		return
+	}
+	commentID, err := strconv.ParseUint(commentEditor.GetAttribute("data-id"), 10, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	// Optimistically update the comment. Its checkboxes are disabled until the edit
+	// is saved, so that edits of the same comment don't conflict with each other.
+	commentEditor.SetAttribute("data-raw", body)
+	setDisabled(checkboxes, true)
+	hideNotification()
+	go func() {
+		defer setDisabled(checkboxes, false)
+		cr := issues.CommentRequest{
+			ID:   commentID,
+			Body: &body,
+		}
+		_, err := f.editComment(context.Background(), cr, common.BodyVersion(prevRaw))
+		if err == nil {
+			return
+		}
+		// Roll back the optimistic update.
+		commentEditor.SetAttribute("data-raw", prevRaw)
+		checkbox.Checked = !checked
+		if err == httpclient.ErrConflict {
+			err = errors.New("someone else edited this comment, reload the page to see their changes")
+		}
+		showError("Updating task", err)
+	}()
+}
+
+func setDisabled(checkboxes []dom.Element, disabled bool) {
+	for _, e := range checkboxes {
+		e.(*dom.HTMLInputElement).Disabled = disabled
+	}
+}
+
+// renderEditableComment renders the Markdown body of a comment that the user can edit
+// into markdownBody, the same way the server does. If previews are rendered by the server,
+// it's rendered there, unless the comment changes again in the meantime.
+func renderEditableComment(markdownBody dom.Element, commentEditor *dom.HTMLTextAreaElement, body string) {
+	if !serverRendered() {
+		markdownBody.SetInnerHTML(string(common.EnableTasks(renderMarkdown([]byte(body)), body)))
+		return
+	}
+	go func() {
+		rendered, err := renderPreview(body)
+		if err != nil {
+			showError("Rendering comment", err)
+			return
+		}
+		if commentEditor.GetAttribute("data-raw") != body {
+			return
+		}
+		markdownBody.SetInnerHTML(string(common.EnableTasks([]byte(rendered), body)))
+	}()
+}
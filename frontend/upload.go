@@ -3,8 +3,8 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/gopherjs/gopherjs/js"
@@ -35,7 +35,7 @@ func PasteHandler(e dom.Event) {
 
 		resp, err := http.Post("/api/usercontent", "image/png", bytes.NewReader(b))
 		if err != nil {
-			log.Println(err)
+			showError("Uploading image", err)
 			return
 		}
 		defer resp.Body.Close()
@@ -45,11 +45,11 @@ func PasteHandler(e dom.Event) {
 		}
 		err = json.NewDecoder(resp.Body).Decode(&uploadResponse)
 		if err != nil {
-			log.Println(err)
+			showError("Uploading image", err)
 			return
 		}
 		if uploadResponse.Error != "" {
-			log.Println(uploadResponse.Error)
+			showError("Uploading image", errors.New(uploadResponse.Error))
 			return
 		}
 
@@ -58,9 +58,8 @@ func PasteHandler(e dom.Event) {
 }
 
 func insertText(t *dom.HTMLTextAreaElement, inserted string) {
-	value, start, end := t.Value, t.SelectionStart, t.SelectionEnd
-	t.Value = value[:start] + inserted + value[end:]
-	t.SelectionStart, t.SelectionEnd = start+len(inserted), start+len(inserted)
+	value, start, end := selection(t)
+	setSelection(t, value[:start]+inserted+value[end:], start+len(inserted), start+len(inserted))
 }
 
 // imagePNGFile tries to get an "image/png" file from items.
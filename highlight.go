@@ -0,0 +1,193 @@
+package issuesapp
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/issuesapp/common"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// highlightCode returns rendered HTML with the fenced code blocks of supported languages
+// syntax highlighted. Tokens are wrapped in spans with the same short CSS classes as Pygments
+// uses, e.g., "k" for keywords and "s" for strings. Code blocks that are already highlighted
+// are left as is.
+//
+// Both code blocks rendered by GitHub Flavored Markdown, i.e.,
+// <div class="highlight highlight-go"><pre>...</pre></div>, and by other common renderers,
+// i.e., <pre><code class="language-go">...</code></pre>, are supported.
+func highlightCode(rendered []byte) []byte {
+	if !bytes.Contains(rendered, []byte("<pre")) {
+		// No code blocks, so skip parsing and rendering the HTML again.
+		return rendered
+	}
+	return common.RewriteNodes(rendered, func(n *html.Node) {
+		if n.Type != html.ElementNode || n.FirstChild == nil || n.FirstChild != n.LastChild || n.FirstChild.Type != html.TextNode {
+			return
+		}
+		var lang string
+		switch {
+		case n.DataAtom == atom.Pre && n.Parent != nil && n.Parent.DataAtom == atom.Div:
+			lang = classWithPrefix(n.Parent, "highlight-")
+		case n.DataAtom == atom.Code && n.Parent != nil && n.Parent.DataAtom == atom.Pre:
+			lang = classWithPrefix(n, "language-")
+		}
+		lexer, ok := lexers[strings.ToLower(lang)]
+		if !ok {
+			return
+		}
+		code := n.FirstChild.Data
+		n.RemoveChild(n.FirstChild)
+		htmlg.AppendChildren(n, lexer.highlight(code)...)
+	})
+}
+
+// classWithPrefix returns the rest of the first class of n that has prefix, if any.
+func classWithPrefix(n *html.Node, prefix string) string {
+	for _, a := range n.Attr {
+		if a.Key != atom.Class.String() {
+			continue
+		}
+		for _, class := range strings.Fields(a.Val) {
+			if strings.HasPrefix(class, prefix) {
+				return class[len(prefix):]
+			}
+		}
+	}
+	return ""
+}
+
+// lexer is a simple regexp-based lexer, good enough for highlighting code snippets.
+type lexer []lexerRule
+
+// lexerRule is a rule for a token of a lexer.
+type lexerRule struct {
+	pattern   *regexp.Regexp // Pattern is anchored at the start of text.
+	class     string         // CSS class of the token, or "" for plain text.
+	lineStart bool           // Whether the token only occurs at the start of a line.
+}
+
+func rule(pattern, class string) lexerRule {
+	return lexerRule{pattern: regexp.MustCompile(`\A(?:` + pattern + `)`), class: class}
+}
+
+// lineRule is like rule, but the token only occurs at the start of a line.
+func lineRule(pattern, class string) lexerRule {
+	r := rule(pattern, class)
+	r.lineStart = true
+	return r
+}
+
+// highlight returns code split into text and spans of highlighted tokens.
+func (l lexer) highlight(code string) []*html.Node {
+	var ns []*html.Node
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			ns = append(ns, htmlg.Text(plain.String()))
+			plain.Reset()
+		}
+	}
+	lineStart := true
+	for len(code) > 0 {
+		var matched bool
+		for _, r := range l {
+			if r.lineStart && !lineStart {
+				continue
+			}
+			token := r.pattern.FindString(code)
+			if token == "" {
+				continue
+			}
+			switch r.class {
+			case "":
+				plain.WriteString(token)
+			default:
+				flush()
+				ns = append(ns, htmlg.SpanClass(r.class, htmlg.Text(token)))
+			}
+			lineStart = strings.HasSuffix(token, "\n")
+			code = code[len(token):]
+			matched = true
+			break
+		}
+		if !matched {
+			// Consume a single byte as plain text. Multi-byte characters
+			// end up in the same text node, so they're not split.
+			lineStart = code[0] == '\n'
+			plain.WriteByte(code[0])
+			code = code[1:]
+		}
+	}
+	flush()
+	return ns
+}
+
+// lexers maps fenced code block languages to their lexers.
+var lexers = map[string]lexer{
+	"go":      goLexer,
+	"golang":  goLexer,
+	"diff":    diffLexer,
+	"patch":   diffLexer,
+	"sh":      shellLexer,
+	"bash":    shellLexer,
+	"shell":   shellLexer,
+	"console": shellLexer,
+	"zsh":     shellLexer,
+	"json":    jsonLexer,
+	"yaml":    yamlLexer,
+	"yml":     yamlLexer,
+}
+
+var goLexer = lexer{
+	rule(`//[^\n]*|/\*[\s\S]*?(?:\*/|\z)`, "c"), // Unterminated comments run to the end.
+	rule("`[^`]*`", "s"),
+	rule(`"(?:\\.|[^"\\\n])*"`, "s"),
+	rule(`'(?:\\.|[^'\\\n])+'`, "s"),
+	rule(`\b(?:break|case|chan|const|continue|default|defer|else|fallthrough|for|func|go|goto|if|import|interface|map|package|range|return|select|struct|switch|type|var)\b`, "k"),
+	rule(`\b(?:true|false|nil|iota)\b`, "kc"),
+	rule(`\b(?:bool|byte|complex64|complex128|error|float32|float64|int|int8|int16|int32|int64|rune|string|uint|uint8|uint16|uint32|uint64|uintptr)\b`, "kt"),
+	rule(`\b(?:append|cap|close|complex|copy|delete|imag|len|make|new|panic|print|println|real|recover)\b`, "nb"),
+	rule(`\b(?:0[xX][0-9a-fA-F_]+|[0-9][0-9_]*(?:\.[0-9_]*)?(?:[eE][+-]?[0-9]+)?i?)\b`, "m"),
+	rule(`[A-Za-z_][A-Za-z0-9_]*`, ""), // Identifiers, so that keywords aren't matched within them.
+	rule(`[-+*/%&|^<>=!:]+`, "o"),
+}
+
+var diffLexer = lexer{
+	rule(`(?:diff|index) [^\n]*|(?:---|\+\+\+) [^\n]*`, "gh"),
+	rule(`@@[^\n]*`, "gu"),
+	rule(`\+[^\n]*`, "gi"),
+	rule(`-[^\n]*`, "gd"),
+	rule(`[^\n]*\n?`, ""),
+}
+
+var shellLexer = lexer{
+	lineRule(`[$#] `, "gp"),
+	rule(`#[^\n]*`, "c"),
+	rule(`'[^']*'`, "s"),
+	rule(`"(?:\\.|[^"\\])*"`, "s"),
+	rule(`\$(?:\{[^}\n]*\}|[A-Za-z_][A-Za-z0-9_]*|[0-9?@#$*!-])`, "nv"),
+	rule(`\b(?:if|then|else|elif|fi|for|while|until|do|done|case|esac|in|function|export|local|return)\b`, "k"),
+	rule(`[A-Za-z0-9_./-]+`, ""), // Words, so that keywords aren't matched within them.
+}
+
+var jsonLexer = lexer{
+	rule(`"(?:\\.|[^"\\\n])*"\s*:`, "nt"),
+	rule(`"(?:\\.|[^"\\\n])*"`, "s"),
+	rule(`-?[0-9]+(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?`, "m"),
+	rule(`\b(?:true|false|null)\b`, "kc"),
+}
+
+var yamlLexer = lexer{
+	rule(`#[^\n]*`, "c"),
+	lineRule(`(?:---|\.\.\.)(?:\n|$)`, "gh"),
+	lineRule(`[ \t]*(?:- +)*[^\s#:'"{}\[\],&*!|>%@`+"`"+`-][^\n:#]*:`, "nt"),
+	rule(`'[^'\n]*'|"(?:\\.|[^"\\\n])*"`, "s"),
+	rule(`\b(?:true|false|yes|no|on|off|null)\b|~`, "kc"),
+	rule(`-?\b[0-9]+(?:\.[0-9]+)?\b`, "m"),
+	rule(`[&*][A-Za-z0-9_-]+`, "nv"),
+	rule(`[A-Za-z_][A-Za-z0-9_]*`, ""),
+}
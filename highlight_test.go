@@ -0,0 +1,54 @@
+package issuesapp
+
+import (
+	"html"
+	"testing"
+)
+
+func TestHighlightCode(t *testing.T) {
+	tests := []struct {
+		lang string
+		code string
+		want string // Highlighted contents of the code element.
+	}{
+		{"go", `x := "a<b" // c & d`, `x <span class="o">:=</span> <span class="s">&#34;a&lt;b&#34;</span> <span class="c">// c &amp; d</span>`},
+		{"go", "/* a\nb */ func funcs() int { return 0x1F }", "<span class=\"c\">/* a\nb */</span> <span class=\"k\">func</span> funcs() <span class=\"kt\">int</span> { <span class=\"k\">return</span> <span class=\"m\">0x1F</span> }"},
+		{"go", "s := `raw\n` + 'c' + nil", "s <span class=\"o\">:=</span> <span class=\"s\">`raw\n`</span> <span class=\"o\">+</span> <span class=\"s\">&#39;c&#39;</span> <span class=\"o\">+</span> <span class=\"kc\">nil</span>"},
+		{"go", `"unterminated`, `&#34;unterminated`},
+		{"go", "/* unterminated\nx", "<span class=\"c\">/* unterminated\nx</span>"},
+		{"diff", "--- a\n+++ b\n@@ -1 +1 @@\n-old <\n+new &\n ctx", "<span class=\"gh\">--- a</span>\n<span class=\"gh\">+++ b</span>\n<span class=\"gu\">@@ -1 +1 @@</span>\n<span class=\"gd\">-old &lt;</span>\n<span class=\"gi\">+new &amp;</span>\n ctx"},
+		{"sh", "$ echo \"$HOME\" 'a&b' $1 # c\nif true; then done; fi", "<span class=\"gp\">$ </span>echo <span class=\"s\">&#34;$HOME&#34;</span> <span class=\"s\">&#39;a&amp;b&#39;</span> <span class=\"nv\">$1</span> <span class=\"c\"># c</span>\n<span class=\"k\">if</span> true; <span class=\"k\">then</span> <span class=\"k\">done</span>; <span class=\"k\">fi</span>"},
+		{"sh", "echo $ iffy 'unterminated", `echo $ iffy &#39;unterminated`},
+		{"json", `{"a": "x<y", "b": -1.5e3, "c": [true, null]}`, `{<span class="nt">&#34;a&#34;:</span> <span class="s">&#34;x&lt;y&#34;</span>, <span class="nt">&#34;b&#34;:</span> <span class="m">-1.5e3</span>, <span class="nt">&#34;c&#34;:</span> [<span class="kc">true</span>, <span class="kc">null</span>]}`},
+		{"json", `{"unterminated`, `{&#34;unterminated`},
+		{"yaml", "---\nkey: value # c\nref: *anchor\nn: 1", "<span class=\"gh\">---\n</span><span class=\"nt\">key:</span> value <span class=\"c\"># c</span>\n<span class=\"nt\">ref:</span> <span class=\"nv\">*anchor</span>\n<span class=\"nt\">n:</span> <span class=\"m\">1</span>"},
+		{"yaml", `key: "unterminated`, `<span class="nt">key:</span> &#34;unterminated`},
+	}
+	for _, tc := range tests {
+		in := `<pre><code class="language-` + tc.lang + `">` + html.EscapeString(tc.code) + `</code></pre>`
+		got := string(highlightCode([]byte(in)))
+		want := `<pre><code class="language-` + tc.lang + `">` + tc.want + `</code></pre>`
+		if got != want {
+			t.Errorf("highlightCode(%q):\ngot  %s\nwant %s", in, got, want)
+		}
+	}
+}
+
+func TestHighlightCodeBlocks(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		// GitHub Flavored Markdown code blocks, with any case of language.
+		{`<div class="highlight highlight-Go"><pre>nil</pre></div>`, `<div class="highlight highlight-Go"><pre><span class="kc">nil</span></pre></div>`},
+		// Unsupported languages and code that's already highlighted are left as is.
+		{`<pre><code class="language-cobol">nil</code></pre>`, `<pre><code class="language-cobol">nil</code></pre>`},
+		{`<pre><code class="language-go"><span class="kc">nil</span></code></pre>`, `<pre><code class="language-go"><span class="kc">nil</span></code></pre>`},
+		{`<p><code class="language-go">nil</code></p>`, `<p><code class="language-go">nil</code></p>`},
+	}
+	for _, tc := range tests {
+		if got := string(highlightCode([]byte(tc.in))); got != tc.want {
+			t.Errorf("highlightCode(%q):\ngot  %s\nwant %s", tc.in, got, tc.want)
+		}
+	}
+}
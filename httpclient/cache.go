@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"container/list"
+	"sync"
+)
+
+// responseCacheSize is the number of responses an Issues client caches.
+const responseCacheSize = 100
+
+// responseCache is a bounded LRU cache of responses, keyed by URL.
+// It's safe for concurrent use.
+type responseCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // Most recently used entries first. Values are *responseCacheEntry.
+}
+
+type cachedResponse struct {
+	etag string // Entity tag of the response.
+	body []byte
+}
+
+type responseCacheEntry struct {
+	url string
+	cachedResponse
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the cached response for url, if any.
+func (c *responseCache) Get(url string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	if !ok {
+		return cachedResponse{}, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*responseCacheEntry).cachedResponse, true
+}
+
+// Add caches resp as the response for url, evicting
+// the least recently used response if the cache is full.
+func (c *responseCache) Add(url string, resp cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[url]; ok {
+		e.Value.(*responseCacheEntry).cachedResponse = resp
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[url] = c.lru.PushFront(&responseCacheEntry{url: url, cachedResponse: resp})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).url)
+	}
+}
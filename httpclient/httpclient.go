@@ -4,6 +4,7 @@ package httpclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -24,14 +25,19 @@ func NewIssues(httpClient *http.Client, scheme, host string) issues.Service {
 			Scheme: scheme,
 			Host:   host,
 		},
+		cache: newResponseCache(responseCacheSize),
 	}
 }
 
 // Issues implements issues.Service remotely over HTTP.
 // Use NewIssues for creation, zero value of Issues is unfit for use.
+//
+// Responses of List, ListComments and ListEvents are cached,
+// and revalidated with If-None-Match on repeat calls.
 type Issues struct {
-	client  *http.Client // HTTP client for API requests. If nil, http.DefaultClient should be used.
-	baseURL *url.URL     // Base URL for API requests.
+	client  *http.Client   // HTTP client for API requests. If nil, http.DefaultClient should be used.
+	baseURL *url.URL       // Base URL for API requests.
+	cache   *responseCache // Cache of responses with an entity tag.
 }
 
 func (i *Issues) List(ctx context.Context, repo issues.RepoSpec, opt issues.IssueListOptions) ([]issues.Issue, error) {
@@ -42,17 +48,8 @@ func (i *Issues) List(ctx context.Context, repo issues.RepoSpec, opt issues.Issu
 			"OptState": {string(opt.State)},
 		}.Encode(),
 	}
-	resp, err := ctxhttp.Get(ctx, i.client, i.baseURL.ResolveReference(&u).String())
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
-	}
 	var is []issues.Issue
-	err = json.NewDecoder(resp.Body).Decode(&is)
+	err := i.get(ctx, u, &is)
 	return is, err
 }
 
@@ -95,17 +92,8 @@ func (i *Issues) ListComments(ctx context.Context, repo issues.RepoSpec, id uint
 		Path:     httproute.ListComments,
 		RawQuery: q.Encode(),
 	}
-	resp, err := ctxhttp.Get(ctx, i.client, i.baseURL.ResolveReference(&u).String())
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
-	}
 	var cs []issues.Comment
-	err = json.NewDecoder(resp.Body).Decode(&cs)
+	err := i.get(ctx, u, &cs)
 	return cs, err
 }
 
@@ -122,18 +110,46 @@ func (i *Issues) ListEvents(ctx context.Context, repo issues.RepoSpec, id uint64
 		Path:     httproute.ListEvents,
 		RawQuery: q.Encode(),
 	}
-	resp, err := ctxhttp.Get(ctx, i.client, i.baseURL.ResolveReference(&u).String())
+	var es []issues.Event
+	err := i.get(ctx, u, &es)
+	return es, err
+}
+
+// get gets the JSON response at u, relative to the base URL, into v.
+// Responses with an entity tag are cached, so that they don't need to be
+// sent again when they're unchanged.
+func (i *Issues) get(ctx context.Context, u url.URL, v interface{}) error {
+	url := i.baseURL.ResolveReference(&u).String()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	cached, ok := i.cache.Get(url)
+	if ok {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := ctxhttp.Do(ctx, i.client, req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
+	var body []byte
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		body = cached.body
+	case resp.StatusCode == http.StatusOK:
+		body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			i.cache.Add(url, cachedResponse{etag: etag, body: body})
+		}
+	default:
 		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
+		return fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
 	}
-	var es []issues.Event
-	err = json.NewDecoder(resp.Body).Decode(&es)
-	return es, err
+	return json.Unmarshal(body, v)
 }
 
 func (*Issues) Create(_ context.Context, repo issues.RepoSpec, issue issues.Issue) (issues.Issue, error) {
@@ -149,6 +165,20 @@ func (*Issues) Edit(_ context.Context, repo issues.RepoSpec, id uint64, ir issue
 }
 
 func (i *Issues) EditComment(ctx context.Context, repo issues.RepoSpec, id uint64, cr issues.CommentRequest) (issues.Comment, error) {
+	return i.editComment(ctx, repo, id, cr, "")
+}
+
+// ErrConflict is returned by EditCommentIfVersion when the comment
+// was edited since the version the edit is based on.
+var ErrConflict = errors.New("comment was edited concurrently")
+
+// EditCommentIfVersion is like EditComment, but the edit is rejected with ErrConflict
+// unless the current comment body has version baseVersion, as computed by common.BodyVersion.
+func (i *Issues) EditCommentIfVersion(ctx context.Context, repo issues.RepoSpec, id uint64, cr issues.CommentRequest, baseVersion string) (issues.Comment, error) {
+	return i.editComment(ctx, repo, id, cr, baseVersion)
+}
+
+func (i *Issues) editComment(ctx context.Context, repo issues.RepoSpec, id uint64, cr issues.CommentRequest, baseVersion string) (issues.Comment, error) {
 	u := url.URL{
 		Path: httproute.EditComment,
 		RawQuery: url.Values{
@@ -165,11 +195,17 @@ func (i *Issues) EditComment(ctx context.Context, repo issues.RepoSpec, id uint6
 	if cr.Reaction != nil {
 		data.Set("Reaction", string(*cr.Reaction))
 	}
+	if baseVersion != "" {
+		data.Set("BaseVersion", baseVersion)
+	}
 	resp, err := ctxhttp.PostForm(ctx, i.client, i.baseURL.ResolveReference(&u).String(), data)
 	if err != nil {
 		return issues.Comment{}, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return issues.Comment{}, ErrConflict
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
 		return issues.Comment{}, fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
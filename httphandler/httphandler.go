@@ -2,18 +2,56 @@
 package httphandler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/shurcooL/httperror"
 	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/issuesapp/policy"
 	"github.com/shurcooL/reactions"
+	"github.com/shurcooL/users"
 )
 
 // Issues is an API handler for issues.Service.
+//
+// Responses of List, ListComments and ListEvents have ETag headers, and responses of
+// ListEvents also have Last-Modified headers, since events don't change once created.
+// Conditional requests for unchanged responses get 304 Not Modified.
 type Issues struct {
 	Issues issues.Service
+
+	// Policy, if not nil, is consulted before making changes,
+	// for the authenticated user of Users. Users must be set too.
+	Policy policy.Policy
+	Users  users.Service
+
+	// Locks reports which conversations are locked, unless Issues implements
+	// Locker itself, the same way as issuesapp.Options.Locks. It can be nil.
+	// Reactions to locked conversations are rejected, unless Policy
+	// lets the authenticated user lock them.
+	Locks Locker
+}
+
+// Locker reports whether conversations of issues are locked.
+// Implementations of issuesapp.Locker implement it too.
+type Locker interface {
+	IsLocked(ctx context.Context, repo issues.RepoSpec, id uint64) (bool, error)
+}
+
+// locker returns the Locker of h, or nil if conversations can't be locked.
+func (h Issues) locker() Locker {
+	if l, ok := h.Issues.(Locker); ok {
+		return l
+	}
+	if h.Locks == nil {
+		return nil
+	}
+	return h.Locks
 }
 
 func (h Issues) List(w http.ResponseWriter, req *http.Request) error {
@@ -27,6 +65,11 @@ func (h Issues) List(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return err
 	}
+	var v common.Validator
+	v.Add(is)
+	if v.CheckNotModified(w, req) {
+		return nil
+	}
 	return httperror.JSONResponse{V: is}
 }
 
@@ -71,6 +114,11 @@ func (h Issues) ListComments(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return err
 	}
+	var v common.Validator
+	v.Add(is)
+	if v.CheckNotModified(w, req) {
+		return nil
+	}
 	return httperror.JSONResponse{V: is}
 }
 
@@ -101,6 +149,14 @@ func (h Issues) ListEvents(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return err
 	}
+	var v common.Validator
+	v.Add(es)
+	for _, e := range es {
+		v.Modified(e.CreatedAt)
+	}
+	if v.CheckNotModified(w, req) {
+		return nil
+	}
 	return httperror.JSONResponse{V: es}
 }
 
@@ -129,9 +185,116 @@ func (h Issues) EditComment(w http.ResponseWriter, req *http.Request) error {
 		r := reactions.EmojiID(reaction[0])
 		cr.Reaction = &r
 	}
+	// The comment is only needed to check body edits.
+	var comment issues.Comment
+	if cr.Body != nil {
+		comment, err = h.comment(req.Context(), repo, id, cr.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if h.Policy != nil {
+		err := h.checkPolicy(req, repo, id, cr, comment)
+		if err != nil {
+			return err
+		}
+	}
+	if cr.Reaction != nil {
+		err := h.checkLocked(req, repo, id)
+		if err != nil {
+			return err
+		}
+	}
+	if baseVersion := req.PostForm.Get("BaseVersion"); baseVersion != "" && cr.Body != nil {
+		err := checkBodyVersion(comment, baseVersion)
+		if err != nil {
+			return err
+		}
+	}
 	is, err := h.Issues.EditComment(req.Context(), repo, id, cr)
 	if err != nil {
 		return err
 	}
 	return httperror.JSONResponse{V: is}
 }
+
+// comment returns the comment with commentID of issue issueID.
+func (h Issues) comment(ctx context.Context, repo issues.RepoSpec, issueID, commentID uint64) (issues.Comment, error) {
+	cs, err := h.Issues.ListComments(ctx, repo, issueID, nil)
+	if err != nil {
+		return issues.Comment{}, err
+	}
+	for _, c := range cs {
+		if c.ID == commentID {
+			return c, nil
+		}
+	}
+	return issues.Comment{}, httperror.HTTP{Code: http.StatusNotFound, Err: errors.New("comment not found")}
+}
+
+// checkPolicy returns a permission error if h.Policy doesn't allow
+// the authenticated user to make the comment edit cr. If cr edits
+// the body, comment is the comment being edited.
+func (h Issues) checkPolicy(req *http.Request, repo issues.RepoSpec, issueID uint64, cr issues.CommentRequest, comment issues.Comment) error {
+	user, err := h.Users.GetAuthenticated(req.Context())
+	if err != nil {
+		return err
+	}
+	issue, err := h.Issues.Get(req.Context(), repo, issueID)
+	if err != nil {
+		return err
+	}
+	if cr.Reaction != nil && !h.Policy.CanReact(req.Context(), repo, issue, user) {
+		return os.ErrPermission
+	}
+	if cr.Body != nil && !h.Policy.CanEditComment(req.Context(), repo, issue, comment, user) {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+// checkLocked returns a permission error if the conversation of issue issueID
+// is locked, and h.Policy doesn't let the authenticated user lock it.
+// Only users who can lock a conversation can react while it's locked,
+// so without a policy, nobody can.
+func (h Issues) checkLocked(req *http.Request, repo issues.RepoSpec, issueID uint64) error {
+	l := h.locker()
+	if l == nil {
+		return nil
+	}
+	locked, err := l.IsLocked(req.Context(), repo, issueID)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	if h.Policy == nil {
+		return os.ErrPermission
+	}
+	user, err := h.Users.GetAuthenticated(req.Context())
+	if err != nil {
+		return err
+	}
+	issue, err := h.Issues.Get(req.Context(), repo, issueID)
+	if err != nil {
+		return err
+	}
+	if !h.Policy.CanLock(req.Context(), repo, issue, user) {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+// checkBodyVersion returns a 409 Conflict error if the current body of comment
+// doesn't match baseVersion, as computed by common.BodyVersion.
+//
+// The check is done before the edit, so it can't catch an edit
+// that is made in between. It's meant to catch the common case of
+// two people editing the same comment from stale pages.
+func checkBodyVersion(comment issues.Comment, baseVersion string) error {
+	if common.BodyVersion(comment.Body) != baseVersion {
+		return httperror.HTTP{Code: http.StatusConflict, Err: fmt.Errorf("comment %d was edited since version %q", comment.ID, baseVersion)}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package httphandler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issues/fs"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/issuesapp/httphandler"
+	"github.com/shurcooL/users"
+	"github.com/shurcooL/webdavfs/vfsutil"
+	"golang.org/x/net/webdav"
+)
+
+func TestEditCommentBaseVersion(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := httphandler.Issues{Issues: service}
+
+	tests := []struct {
+		baseVersion string
+		wantCode    int
+	}{
+		{common.BodyVersion("This is a stale body."), http.StatusConflict},
+		{common.BodyVersion("This is a test issue."), http.StatusOK},
+		{"", http.StatusOK}, // No version, no check.
+	}
+	for _, tc := range tests {
+		code := editComment(h, repo, url.Values{"ID": {"0"}, "Body": {"Edited."}, "BaseVersion": {tc.baseVersion}})
+		if code != tc.wantCode {
+			t.Errorf("edit with BaseVersion %q: got %v, want %v", tc.baseVersion, http.StatusText(code), http.StatusText(tc.wantCode))
+		}
+		if code == http.StatusOK {
+			// Restore the body for the next case.
+			editComment(h, repo, url.Values{"ID": {"0"}, "Body": {"This is a test issue."}})
+		}
+	}
+}
+
+func TestEditCommentBaseVersionCRLF(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := httphandler.Issues{Issues: service}
+
+	if code := editComment(h, repo, url.Values{"ID": {"0"}, "Body": {"First line.\r\nSecond line."}}); code != http.StatusOK {
+		t.Fatalf("edit: got %v, want %v", http.StatusText(code), http.StatusText(http.StatusOK))
+	}
+	// The frontend reads the body from an HTML attribute, where CRLF becomes LF.
+	baseVersion := common.BodyVersion("First line.\nSecond line.")
+	if code := editComment(h, repo, url.Values{"ID": {"0"}, "Body": {"Edited."}, "BaseVersion": {baseVersion}}); code != http.StatusOK {
+		t.Errorf("edit of CRLF body with LF BaseVersion: got %v, want %v", http.StatusText(code), http.StatusText(http.StatusOK))
+	}
+}
+
+func TestEditCommentLocked(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := httphandler.Issues{Issues: service, Locks: mockLocks{1: true}}
+
+	// Without a policy, nobody can react while the conversation is locked.
+	if got, want := editComment(h, repo, url.Values{"ID": {"0"}, "Reaction": {"+1"}}), http.StatusForbidden; got != want {
+		t.Errorf("reaction to locked issue: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	h.Locks = mockLocks{}
+	if got, want := editComment(h, repo, url.Values{"ID": {"0"}, "Reaction": {"+1"}}), http.StatusOK; got != want {
+		t.Errorf("reaction to unlocked issue: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+
+	// An issues service that implements Locker is used rather than Locks, as in the issues app.
+	h.Issues = lockingService{Service: service, Locker: mockLocks{1: true}}
+	if got, want := editComment(h, repo, url.Values{"ID": {"0"}, "Reaction": {"+1"}}), http.StatusForbidden; got != want {
+		t.Errorf("reaction to issue locked by issues service: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+}
+
+// editComment posts a comment edit of issue 1 in repo to h,
+// and returns the status code of the response.
+func editComment(h httphandler.Issues, repo issues.RepoSpec, form url.Values) int {
+	req := httptest.NewRequest("POST", "/api/issues/edit-comment?"+url.Values{"RepoURI": {repo.URI}, "ID": {"1"}}.Encode(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return statusCode(h.EditComment(httptest.NewRecorder(), req))
+}
+
+// statusCode returns the status code of a response to err returned by a handler.
+func statusCode(err error) int {
+	if _, ok := httperror.IsJSONResponse(err); ok || err == nil {
+		return http.StatusOK
+	}
+	if err, ok := httperror.IsHTTP(err); ok {
+		return err.Code
+	}
+	if _, ok := httperror.IsBadRequest(err); ok {
+		return http.StatusBadRequest
+	}
+	if os.IsPermission(err) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// mockIssuesService returns an issues service with issue 1 in repo,
+// created by the signed in user of mockUsers.
+func mockIssuesService(repo issues.RepoSpec) (issues.Service, error) {
+	mem := webdav.NewMemFS()
+	err := vfsutil.MkdirAll(context.Background(), mem, path.Join(repo.URI, "issues"), 0700)
+	if err != nil {
+		return nil, err
+	}
+	service, err := fs.NewService(mem, nil, nil, mockUsers{})
+	if err != nil {
+		return nil, err
+	}
+	_, err = service.Create(context.Background(), repo, issues.Issue{
+		Title:   "Some issue about something",
+		Comment: issues.Comment{Body: "This is a test issue."},
+	})
+	return service, err
+}
+
+// mockUsers is a users service with a single user, who's always signed in.
+type mockUsers struct{}
+
+var gopher = users.User{UserSpec: users.UserSpec{ID: 1, Domain: "example.org"}, Login: "gopher"}
+
+func (mockUsers) Get(_ context.Context, user users.UserSpec) (users.User, error) {
+	if user != gopher.UserSpec {
+		return users.User{}, os.ErrNotExist
+	}
+	return gopher, nil
+}
+
+func (mockUsers) GetAuthenticatedSpec(context.Context) (users.UserSpec, error) {
+	return gopher.UserSpec, nil
+}
+
+func (mockUsers) GetAuthenticated(context.Context) (users.User, error) {
+	return gopher, nil
+}
+
+func (mockUsers) Edit(context.Context, users.EditRequest) (users.User, error) {
+	return users.User{}, os.ErrPermission
+}
+
+// lockingService is an issues service that implements Locker.
+type lockingService struct {
+	issues.Service
+	httphandler.Locker
+}
+
+// mockLocks is a Locker of a single repository, keyed by issue ID.
+type mockLocks map[uint64]bool
+
+func (m mockLocks) IsLocked(_ context.Context, _ issues.RepoSpec, id uint64) (bool, error) {
+	return m[id], nil
+}
@@ -0,0 +1,82 @@
+package httphandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issuesapp/accesstoken"
+)
+
+// Tokens is an API handler for managing personal access tokens
+// of the authenticated user.
+//
+// Its handlers and those of Issues should be wrapped with
+// accesstoken.Service.Authenticate, so that API clients can use tokens.
+// Tokens themselves can't be used to manage tokens.
+type Tokens struct {
+	Tokens *accesstoken.Service
+}
+
+// Create creates a token. Its response includes the token itself
+// in the Secret field, which isn't available later.
+func (h Tokens) Create(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := req.ParseForm(); err != nil {
+		return httperror.BadRequest{Err: err}
+	}
+	name := strings.TrimSpace(req.PostForm.Get("Name"))
+	if name == "" {
+		return httperror.BadRequest{Err: errors.New("missing Name form parameter")}
+	}
+	var scopes []accesstoken.Scope
+	for _, s := range req.PostForm["Scope"] {
+		switch s := accesstoken.Scope(s); s {
+		case accesstoken.Read, accesstoken.Write:
+			scopes = append(scopes, s)
+		default:
+			return httperror.BadRequest{Err: fmt.Errorf("unknown scope %q", s)}
+		}
+	}
+	if len(scopes) == 0 {
+		return httperror.BadRequest{Err: errors.New("missing Scope form parameter")}
+	}
+	token, t, err := h.Tokens.Create(req.Context(), name, scopes)
+	if err != nil {
+		return err
+	}
+	return httperror.JSONResponse{V: struct {
+		accesstoken.Token
+		Secret string // The token itself.
+	}{t, token}}
+}
+
+func (h Tokens) List(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "GET" {
+		return httperror.Method{Allowed: []string{"GET"}}
+	}
+	ts, err := h.Tokens.List(req.Context())
+	if err != nil {
+		return err
+	}
+	return httperror.JSONResponse{V: ts}
+}
+
+func (h Tokens) Revoke(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := req.ParseForm(); err != nil {
+		return httperror.BadRequest{Err: err}
+	}
+	err := h.Tokens.Revoke(req.Context(), req.PostForm.Get("ID"))
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -8,4 +8,8 @@ const (
 	ListComments = "/api/issues/list-comments"
 	ListEvents   = "/api/issues/list-events"
 	EditComment  = "/api/issues/edit-comment"
+
+	CreateToken = "/api/tokens/create"
+	ListTokens  = "/api/tokens/list"
+	RevokeToken = "/api/tokens/revoke"
 )
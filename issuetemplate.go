@@ -0,0 +1,111 @@
+package issuesapp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/issues"
+	"gopkg.in/yaml.v2"
+)
+
+// issueTemplate is a template for new issues, such as a bug report.
+// It's loaded from a Markdown file with YAML front matter, for example:
+//
+// 	---
+// 	name: Bug report
+// 	about: Report something that doesn't work.
+// 	title: "bug: "
+// 	labels: [bug]
+// 	---
+// 	### What did you do?
+//
+// 	### What did you expect to see?
+type issueTemplate struct {
+	ID     string   `yaml:"-"`      // ID is the file name without the ".md" extension.
+	Name   string   `yaml:"name"`   // Name is displayed in the template chooser.
+	About  string   `yaml:"about"`  // About is an optional description of when to use the template.
+	Title  string   `yaml:"title"`  // Title is a prefix that the title editor starts with.
+	Labels []string `yaml:"labels"` // Labels are names of labels that new issues get.
+	Body   string   `yaml:"-"`      // Body is the Markdown that the comment editor starts with.
+}
+
+// issueTemplates loads the issue templates of repo from Options.IssueTemplates,
+// sorted by ID. There are none if the repository has no directory there.
+func (h *handler) issueTemplates(repo issues.RepoSpec) ([]issueTemplate, error) {
+	if h.IssueTemplates == nil {
+		return nil, nil
+	}
+	dir := path.Join("/", repo.URI)
+	f, err := h.IssueTemplates.Open(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	fis, err := f.Readdir(0)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading issue templates in %q: %v", dir, err)
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	var ts []issueTemplate
+	for _, fi := range fis {
+		if fi.IsDir() || path.Ext(fi.Name()) != ".md" {
+			continue
+		}
+		t, err := loadIssueTemplate(h.IssueTemplates, path.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// findIssueTemplate returns the issue template with id among ts.
+func findIssueTemplate(ts []issueTemplate, id string) (issueTemplate, bool) {
+	for _, t := range ts {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return issueTemplate{}, false
+}
+
+// loadIssueTemplate loads the issue template in the file at name.
+func loadIssueTemplate(fs http.FileSystem, name string) (issueTemplate, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return issueTemplate{}, err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return issueTemplate{}, fmt.Errorf("reading issue template %q: %v", name, err)
+	}
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+
+	t := issueTemplate{ID: strings.TrimSuffix(path.Base(name), ".md")}
+	if bytes.HasPrefix(b, []byte("---\n")) {
+		end := bytes.Index(b[len("---\n"):], []byte("\n---\n"))
+		if end == -1 {
+			return issueTemplate{}, fmt.Errorf("issue template %q: front matter isn't terminated by a --- line", name)
+		}
+		err := yaml.UnmarshalStrict(b[len("---\n"):len("---\n")+end], &t)
+		if err != nil {
+			return issueTemplate{}, fmt.Errorf("parsing front matter of issue template %q: %v", name, err)
+		}
+		b = b[len("---\n")+end+len("\n---\n"):]
+	}
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	t.Body = strings.TrimLeft(string(b), "\n")
+	return t, nil
+}
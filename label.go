@@ -0,0 +1,146 @@
+package issuesapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/users"
+)
+
+// LabelLister is an optional interface that an issues.Service can implement
+// to list the labels of a repository, which are offered when creating issues.
+// Otherwise, the labels of existing issues of the repository are offered.
+type LabelLister interface {
+	// ListLabels lists the labels of repo.
+	ListLabels(ctx context.Context, repo issues.RepoSpec) ([]issues.Label, error)
+}
+
+// defaultLabelColor is the color of labels that aren't known in a repository.
+var defaultLabelColor = issues.RGB{R: 237, G: 237, B: 237}
+
+// repoLabels returns the labels known in repo, sorted by name.
+func (h *handler) repoLabels(ctx context.Context, repo issues.RepoSpec) ([]issues.Label, error) {
+	if ll, ok := h.is.(LabelLister); ok {
+		ls, err := ll.ListLabels(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("ListLabels: %v", err)
+		}
+		return ls, nil
+	}
+	is, err := h.is.List(ctx, repo, issues.IssueListOptions{State: issues.AllStates})
+	if err != nil {
+		return nil, err
+	}
+	var ls []issues.Label
+	for _, i := range is {
+		for _, l := range i.Labels {
+			if !hasLabel(ls, l.Name) {
+				ls = append(ls, l)
+			}
+		}
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+	return ls, nil
+}
+
+// labelChoice is a label in the label selector of the new issue page.
+type labelChoice struct {
+	issues.Label
+	Checked  bool
+	Disabled bool // Disabled is set for labels of the issue template, which are always applied.
+}
+
+// labelChoices returns the labels that user can choose from when creating
+// an issue in repo. Labels of issue template t, if any, come first.
+func (h *handler) labelChoices(ctx context.Context, repo issues.RepoSpec, user users.User, t *issueTemplate) ([]labelChoice, error) {
+	known, err := h.repoLabels(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var cs []labelChoice
+	var templateLabels []string
+	if t != nil {
+		templateLabels = t.Labels
+	}
+	for _, name := range templateLabels {
+		cs = append(cs, labelChoice{Label: knownLabel(known, name), Checked: true, Disabled: true})
+	}
+	for _, l := range known {
+		if containsString(templateLabels, l.Name) || !h.policy().CanApplyLabel(ctx, repo, l.Name, user) {
+			continue
+		}
+		cs = append(cs, labelChoice{Label: l})
+	}
+	return cs, nil
+}
+
+// issueLabels returns the labels of a new issue in repo, made of labels
+// requested by user, followed by labels of issue template t, if any.
+// Labels get the colors they have in repo, so only their names are used.
+// It returns a 403 error if the policy doesn't let user apply a requested label.
+func (h *handler) issueLabels(ctx context.Context, repo issues.RepoSpec, user users.User, requested []issues.Label, t *issueTemplate) ([]issues.Label, error) {
+	if len(requested) == 0 && t == nil {
+		return nil, nil
+	}
+	known, err := h.repoLabels(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var ls []issues.Label
+	for _, l := range requested {
+		name := strings.TrimSpace(l.Name)
+		if name == "" {
+			return nil, httperror.BadRequest{Err: fmt.Errorf("label name can't be blank")}
+		}
+		if hasLabel(ls, name) {
+			continue
+		}
+		if !h.policy().CanApplyLabel(ctx, repo, name, user) {
+			return nil, httperror.HTTP{Code: http.StatusForbidden, Err: fmt.Errorf("user %v can't apply label %q", user.UserSpec, name)}
+		}
+		ls = append(ls, knownLabel(known, name))
+	}
+	if t != nil {
+		for _, name := range t.Labels {
+			if !hasLabel(ls, name) {
+				ls = append(ls, knownLabel(known, name))
+			}
+		}
+	}
+	return ls, nil
+}
+
+// knownLabel returns the label with name among known,
+// or a label with defaultLabelColor if it isn't there.
+func knownLabel(known []issues.Label, name string) issues.Label {
+	for _, l := range known {
+		if l.Name == name {
+			return l
+		}
+	}
+	return issues.Label{Name: name, Color: defaultLabelColor}
+}
+
+// hasLabel reports whether ls has a label with name.
+func hasLabel(ls []issues.Label, name string) bool {
+	for _, l := range ls {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
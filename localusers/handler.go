@@ -0,0 +1,116 @@
+package localusers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/httperror"
+)
+
+// SignIn returns HTML with a link to sign in, which returns to returnURL
+// afterwards. It's meant for issuesapp.Options.SignIn.
+func (s *Service) SignIn(returnURL string) template.HTML {
+	u := url.URL{Path: "/login", RawQuery: url.Values{returnQueryKey: {returnURL}}.Encode()}
+	return template.HTML(`<a href="` + template.HTMLEscapeString(u.String()) + `">Sign in</a>`)
+}
+
+const (
+	// returnQueryKey is name of query key for the URL to return to
+	// after signing in or out.
+	returnQueryKey = "return"
+)
+
+// Login handles requests to sign in, at "/login". GET requests show
+// a sign in form, and POST requests sign in with a login and password.
+// The user is then redirected to the URL in the "return" query.
+func (s *Service) Login(w http.ResponseWriter, req *http.Request) error {
+	returnURL := safeReturnURL(req.URL.Query().Get(returnQueryKey))
+	switch req.Method {
+	case http.MethodGet:
+		if _, ok := s.sessionUser(req); ok {
+			return httperror.Redirect{URL: returnURL}
+		}
+		return renderLoginForm(w, loginForm{Return: returnURL})
+	case http.MethodPost:
+		if err := req.ParseForm(); err != nil {
+			return httperror.BadRequest{Err: err}
+		}
+		login := req.PostForm.Get("login")
+		a, err := s.checkPassword(login, req.PostForm.Get("password"))
+		if err == errIncorrectPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return renderLoginForm(w, loginForm{Return: returnURL, Login: login, Error: "Incorrect login or password."})
+		} else if err != nil {
+			return err
+		}
+		http.SetCookie(w, s.newSessionCookie(a.ID, time.Now(), req.TLS != nil))
+		return httperror.Redirect{URL: returnURL}
+	default:
+		return httperror.Method{Allowed: []string{http.MethodGet, http.MethodPost}}
+	}
+}
+
+// Logout handles POST requests to sign out, at "/logout".
+// The user is then redirected to the URL in the "return" query.
+func (s *Service) Logout(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != http.MethodPost {
+		return httperror.Method{Allowed: []string{http.MethodPost}}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return httperror.Redirect{URL: safeReturnURL(req.URL.Query().Get(returnQueryKey))}
+}
+
+// safeReturnURL returns returnURL if it's a path on the same site, or "/" otherwise,
+// so that signing in can't be used to redirect to other sites.
+func safeReturnURL(returnURL string) string {
+	if !strings.HasPrefix(returnURL, "/") || strings.HasPrefix(returnURL, "//") || strings.HasPrefix(returnURL, `/\`) {
+		return "/"
+	}
+	return returnURL
+}
+
+type loginForm struct {
+	Return string
+	Login  string
+	Error  string
+}
+
+func renderLoginForm(w http.ResponseWriter, f loginForm) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return loginFormTemplate.Execute(w, f)
+}
+
+var loginFormTemplate = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta name="viewport" content="width=device-width">
+		<title>Sign in</title>
+		<style type="text/css">
+			body { margin: 20px; font-family: sans-serif; font-size: 14px; color: #373a3c; }
+			form { max-width: 300px; margin: 60px auto; }
+			label, input { display: block; width: 100%; box-sizing: border-box; }
+			input { margin: 4px 0 12px 0; padding: 6px; }
+			.error { color: #cb2431; margin-bottom: 12px; }
+		</style>
+	</head>
+	<body>
+		<form method="post" action="/login?return={{.Return}}">
+			<h2>Sign in</h2>
+			{{with .Error}}<div class="error">{{.}}</div>{{end}}
+			<label>Login <input name="login" value="{{.Login}}" autocomplete="username" autofocus required></label>
+			<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+			<input type="submit" value="Sign in">
+		</form>
+	</body>
+</html>
+`))
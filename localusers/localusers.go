@@ -0,0 +1,187 @@
+// Package localusers provides a users.Service with local user accounts,
+// and handlers for signing in to them with a password.
+//
+// Accounts are read from a JSON file with bcrypt-hashed passwords, like:
+//
+// 	[
+// 		{
+// 			"ID": 1,
+// 			"Login": "gopher",
+// 			"Name": "Sample Gopher",
+// 			"Email": "gopher@example.org",
+// 			"PasswordHash": "$2a$10$...",
+// 			"Admin": true
+// 		}
+// 	]
+//
+// HashPassword can be used to compute a password hash.
+package localusers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shurcooL/users"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Account is a local user account.
+type Account struct {
+	ID           uint64 // ID of the user. It must not be 0.
+	Login        string
+	Name         string
+	Email        string // Public email.
+	AvatarURL    string
+	HTMLURL      string
+	PasswordHash string // Password hashed with bcrypt. If empty, it's not possible to sign in.
+	Admin        bool   // Admin is whether the user is a site admin.
+}
+
+// HashPassword returns a bcrypt hash of password, for Account.PasswordHash.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// Service implements users.Service with local user accounts.
+// Its Authenticate middleware must be used for requests whose context
+// is passed to it, so that the signed in user is known.
+type Service struct {
+	domain   string
+	key      []byte             // Key for signing session cookies.
+	accounts map[uint64]Account // Keyed by ID.
+	logins   map[string]uint64  // Lowercase login -> ID.
+}
+
+// minKeyLen is the minimum length of the session key, in bytes.
+const minKeyLen = 32
+
+// NewService returns a users service with the accounts in accountsFile,
+// whose users are in domain. Session cookies are signed with key,
+// which must be secret and at least 32 bytes long.
+func NewService(accountsFile string, domain string, key []byte) (*Service, error) {
+	if len(key) < minKeyLen {
+		return nil, fmt.Errorf("session key is %d bytes long, it must be at least %d", len(key), minKeyLen)
+	}
+	f, err := os.Open(accountsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var accounts []Account
+	err = json.NewDecoder(f).Decode(&accounts)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", accountsFile, err)
+	}
+	s := &Service{
+		domain:   domain,
+		key:      key,
+		accounts: make(map[uint64]Account),
+		logins:   make(map[string]uint64),
+	}
+	for _, a := range accounts {
+		login := strings.ToLower(a.Login)
+		_, dupID := s.accounts[a.ID]
+		_, dupLogin := s.logins[login]
+		switch {
+		case a.ID == 0:
+			return nil, fmt.Errorf("%s: account %q has ID 0", accountsFile, a.Login)
+		case a.Login == "":
+			return nil, fmt.Errorf("%s: account %d has no login", accountsFile, a.ID)
+		case dupID:
+			return nil, fmt.Errorf("%s: account ID %d is used more than once", accountsFile, a.ID)
+		case dupLogin:
+			return nil, fmt.Errorf("%s: account login %q is used more than once", accountsFile, a.Login)
+		}
+		s.accounts[a.ID] = a
+		s.logins[login] = a.ID
+	}
+	return s, nil
+}
+
+func (s *Service) Get(_ context.Context, user users.UserSpec) (users.User, error) {
+	a, ok := s.accounts[user.ID]
+	if !ok || user.Domain != s.domain {
+		return users.User{}, fmt.Errorf("user %v not found", user)
+	}
+	return s.user(a), nil
+}
+
+func (s *Service) GetAuthenticatedSpec(ctx context.Context) (users.UserSpec, error) {
+	user, _ := ctx.Value(userContextKey).(users.UserSpec)
+	return user, nil
+}
+
+func (s *Service) GetAuthenticated(ctx context.Context) (users.User, error) {
+	userSpec, err := s.GetAuthenticatedSpec(ctx)
+	if err != nil {
+		return users.User{}, err
+	}
+	if userSpec.ID == 0 {
+		return users.User{}, nil
+	}
+	return s.Get(ctx, userSpec)
+}
+
+func (s *Service) Edit(ctx context.Context, er users.EditRequest) (users.User, error) {
+	userSpec, err := s.GetAuthenticatedSpec(ctx)
+	if err != nil {
+		return users.User{}, err
+	}
+	if userSpec.ID == 0 {
+		return users.User{}, os.ErrPermission
+	}
+	// There aren't any editable fields yet, so there's nothing to do.
+	return s.Get(ctx, userSpec)
+}
+
+func (s *Service) user(a Account) users.User {
+	return users.User{
+		UserSpec:  users.UserSpec{ID: a.ID, Domain: s.domain},
+		Login:     a.Login,
+		Name:      a.Name,
+		Email:     a.Email,
+		AvatarURL: a.AvatarURL,
+		HTMLURL:   a.HTMLURL,
+		SiteAdmin: a.Admin,
+	}
+}
+
+// errIncorrectPassword is returned when signing in with an incorrect login or password.
+var errIncorrectPassword = errors.New("incorrect login or password")
+
+// dummyHash is compared with passwords of unknown logins,
+// so that they take as long to check as known ones.
+// It's a hash of "dummy password" with the default cost.
+const dummyHash = "$2a$10$q6TdL0wLIRaVnfnd2AK02.oIaBn9UVc74moY6QYMf2b.gN1mrbwhi"
+
+// checkPassword returns the account with login if password is correct.
+func (s *Service) checkPassword(login, password string) (Account, error) {
+	id, ok := s.logins[strings.ToLower(login)]
+	a := s.accounts[id]
+	hash := a.PasswordHash
+	if !ok || hash == "" {
+		hash = dummyHash
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil || !ok || a.PasswordHash == "" {
+		return Account{}, errIncorrectPassword
+	}
+	return a, nil
+}
+
+// contextKey is a value for use with context.WithValue. It's used as
+// a pointer so it fits in an interface{} without allocation.
+type contextKey struct {
+	name string
+}
+
+func (k *contextKey) String() string { return "github.com/shurcooL/issuesapp/localusers context value " + k.name }
+
+// userContextKey is a context key for the signed in user.
+// The associated value will be of type users.UserSpec.
+var userContextKey = &contextKey{"User"}
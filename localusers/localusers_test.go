@@ -0,0 +1,98 @@
+package localusers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/home/httputil"
+	"github.com/shurcooL/issuesapp/localusers"
+	"github.com/shurcooL/users"
+)
+
+func TestLogin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localusers_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hash, err := localusers.HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal([]localusers.Account{{ID: 1, Login: "gopher", PasswordHash: hash, Admin: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountsFile := filepath.Join(dir, "users.json")
+	err = ioutil.WriteFile(accountsFile, b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := localusers.NewService(accountsFile, "example.org", []byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got users.User
+	mux := http.NewServeMux()
+	mux.Handle("/login", httputil.ErrorHandler(s, s.Login))
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		got, err = s.GetAuthenticated(req.Context())
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	h := s.Authenticate(mux)
+
+	login := func(password, returnURL string) *http.Response {
+		form := url.Values{"login": {"Gopher"}, "password": {password}}
+		req := httptest.NewRequest("POST", "/login?return="+url.QueryEscape(returnURL), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	if resp := login("wrong", "/"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("login with incorrect password: got status %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+	resp := login("hunter2", "//evil.example.org/")
+	if got, want := resp.StatusCode, http.StatusSeeOther; got != want {
+		t.Fatalf("login: got status %v, want %v", got, want)
+	}
+	if got, want := resp.Header.Get("Location"), "/"; got != want {
+		t.Errorf("login: got Location %q, want %q", got, want)
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("login: got %d cookies, want 1", len(cookies))
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got.ID != 1 || got.Login != "gopher" || !got.SiteAdmin {
+		t.Errorf("got authenticated user %+v, want gopher with ID 1 who is a site admin", got)
+	}
+
+	// A tampered session cookie must not be accepted.
+	got = users.User{}
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: cookies[0].Name, Value: "2" + cookies[0].Value[1:]})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got.ID != 0 {
+		t.Errorf("got authenticated user %+v with tampered cookie, want none", got)
+	}
+
+	if user, err := s.GetAuthenticatedSpec(context.Background()); err != nil || user.ID != 0 {
+		t.Errorf("GetAuthenticatedSpec without a session: got %v, %v, want no user", user, err)
+	}
+}
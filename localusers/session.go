@@ -0,0 +1,93 @@
+package localusers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/users"
+)
+
+const (
+	// sessionCookieName is the name of the session cookie.
+	sessionCookieName = "session"
+
+	// sessionDuration is how long a session lasts after signing in.
+	sessionDuration = 30 * 24 * time.Hour
+)
+
+// Authenticate returns a handler that serves requests with h, with the user
+// signed in to the session of each request (if any) set in its context.
+// It must be used for requests whose context is passed to s.
+func (s *Service) Authenticate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, ok := s.sessionUser(req); ok {
+			req = req.WithContext(context.WithValue(req.Context(), userContextKey, user))
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// sessionUser returns the user signed in to the session of req, if any.
+func (s *Service) sessionUser(req *http.Request) (users.UserSpec, bool) {
+	cookie, err := req.Cookie(sessionCookieName)
+	if err != nil {
+		return users.UserSpec{}, false
+	}
+	id, ok := s.verifySession(cookie.Value, time.Now())
+	if !ok {
+		return users.UserSpec{}, false
+	}
+	if _, ok := s.accounts[id]; !ok {
+		// The account was removed since signing in.
+		return users.UserSpec{}, false
+	}
+	return users.UserSpec{ID: id, Domain: s.domain}, true
+}
+
+// newSessionCookie returns a session cookie for user with ID id, which expires
+// sessionDuration after now. secure is whether it's only sent over HTTPS.
+func (s *Service) newSessionCookie(id uint64, now time.Time, secure bool) *http.Cookie {
+	expires := now.Add(sessionDuration)
+	payload := fmt.Sprintf("%d.%d", id, expires.Unix())
+	return &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    payload + "." + s.sign(payload),
+		Path:     "/",
+		Expires:  expires,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// verifySession returns the user ID of session cookie value,
+// if it's signed by s and hasn't expired at now.
+func (s *Service) verifySession(value string, now time.Time) (id uint64, ok bool) {
+	i := strings.LastIndexByte(value, '.')
+	if i == -1 {
+		return 0, false
+	}
+	payload, signature := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return 0, false
+	}
+	var expires int64
+	_, err := fmt.Sscanf(payload, "%d.%d", &id, &expires)
+	if err != nil || now.Unix() >= expires {
+		return 0, false
+	}
+	return id, true
+}
+
+// sign returns the signature of payload.
+func (s *Service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,124 @@
+package issuesapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+)
+
+// Locker is an optional interface that an issues.Service can implement
+// to support locking the conversation of an issue. While it's locked, only
+// users that the policy lets lock it can comment and react, and the rest are
+// shown a notice instead of the new comment form.
+// Otherwise, a host-provided LockStore can be set in Options.Locks.
+type Locker interface {
+	// IsLocked reports whether the conversation of issue id is locked.
+	IsLocked(ctx context.Context, repo issues.RepoSpec, id uint64) (bool, error)
+
+	// SetLocked locks or unlocks the conversation of issue id. It returns
+	// the event it recorded, of type common.Locked or common.Unlocked,
+	// which should be listed among the events of the issue afterwards.
+	// The app only calls it to change whether the conversation is locked.
+	SetLocked(ctx context.Context, repo issues.RepoSpec, id uint64, locked bool) (issues.Event, error)
+}
+
+// LockStore is a Locker that stores locked conversations separately from
+// the issues service. Since the issues service doesn't list the events
+// that SetLocked records, the store lists them itself.
+type LockStore interface {
+	Locker
+
+	// ListLockEvents lists the events that SetLocked recorded for issue id.
+	ListLockEvents(ctx context.Context, repo issues.RepoSpec, id uint64) ([]issues.Event, error)
+}
+
+// locker returns the Locker of the app, or nil if conversations can't be locked.
+func (h *handler) locker() Locker {
+	if l, ok := h.is.(Locker); ok {
+		return l
+	}
+	if h.Locks == nil {
+		return nil
+	}
+	return h.Locks
+}
+
+// isLocked reports whether the conversation of issue id is locked.
+// It's never locked if conversations can't be locked.
+func (h *handler) isLocked(ctx context.Context, repo issues.RepoSpec, id uint64) (bool, error) {
+	l := h.locker()
+	if l == nil {
+		return false, nil
+	}
+	locked, err := l.IsLocked(ctx, repo, id)
+	if err != nil {
+		return false, fmt.Errorf("IsLocked: %v", err)
+	}
+	return locked, nil
+}
+
+// lockEventItems returns the lock events of the specified issue,
+// for display in its timeline, if they're stored in Options.Locks.
+// Events recorded by an issues service that implements Locker
+// are listed with its other events.
+func (h *handler) lockEventItems(ctx context.Context, repo issues.RepoSpec, issueID uint64) ([]issueItem, error) {
+	if _, ok := h.is.(Locker); ok || h.Locks == nil {
+		return nil, nil
+	}
+	es, err := h.Locks.ListLockEvents(ctx, repo, issueID)
+	if err != nil {
+		return nil, fmt.Errorf("ListLockEvents: %v", err)
+	}
+	var items []issueItem
+	for _, e := range es {
+		items = append(items, issueItem{e})
+	}
+	return items, nil
+}
+
+// PostLockHandler locks or unlocks the conversation of issue issueID,
+// as specified by the "locked" form value, and redirects back to the issue.
+func (h *handler) PostLockHandler(w http.ResponseWriter, req *http.Request, issueID uint64) error {
+	if req.Method != http.MethodPost {
+		return httperror.Method{Allowed: []string{http.MethodPost}}
+	}
+	if err := req.ParseForm(); err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("req.ParseForm: %v", err)}
+	}
+	locked, err := strconv.ParseBool(req.PostForm.Get("locked"))
+	if err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("parsing locked form value: %v", err)}
+	}
+	l := h.locker()
+	if l == nil {
+		return httperror.HTTP{Code: http.StatusNotFound, Err: fmt.Errorf("issues service doesn't implement Locker, and Options.Locks isn't set")}
+	}
+	state, err := h.state(req, issueID)
+	if err != nil {
+		return err
+	}
+	state.Issue, err = h.is.Get(req.Context(), state.RepoSpec, issueID)
+	if err != nil {
+		return err
+	}
+	if !h.policy().CanLock(req.Context(), state.RepoSpec, state.Issue, state.CurrentUser) {
+		return os.ErrPermission
+	}
+	wasLocked, err := l.IsLocked(req.Context(), state.RepoSpec, issueID)
+	if err != nil {
+		return fmt.Errorf("IsLocked: %v", err)
+	}
+	// Locking a locked conversation (e.g., in another tab) records no event.
+	if locked != wasLocked {
+		_, err = l.SetLocked(req.Context(), state.RepoSpec, issueID, locked)
+		if err != nil {
+			return err
+		}
+	}
+	return httperror.Redirect{URL: fmt.Sprintf("%s/%d", state.BaseURI, issueID)}
+}
@@ -15,10 +15,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
-	"github.com/shurcooL/github_flavored_markdown"
 	"github.com/shurcooL/htmlg"
 	"github.com/shurcooL/httperror"
 	"github.com/shurcooL/httpfs/html/vfstemplate"
@@ -27,25 +27,20 @@ import (
 	"github.com/shurcooL/issuesapp/assets"
 	"github.com/shurcooL/issuesapp/common"
 	"github.com/shurcooL/issuesapp/component"
+	"github.com/shurcooL/issuesapp/policy"
 	"github.com/shurcooL/notifications"
 	"github.com/shurcooL/octicon"
 	"github.com/shurcooL/reactions"
 	reactionscomponent "github.com/shurcooL/reactions/component"
+	"github.com/shurcooL/reactions/emojis"
 	"github.com/shurcooL/users"
 	"golang.org/x/net/html"
 )
 
-// TODO: Find a better way for issuesapp to be able to ensure registration of a top-level route:
-//
-// 	emojisHandler := httpgzip.FileServer(emojis.Assets, httpgzip.FileServerOptions{ServeError: httpgzip.Detailed})
-// 	http.Handle("/emojis/", http.StripPrefix("/emojis", emojisHandler))
-//
-// So that it can depend on it.
-
 // New returns an issues app http.Handler using given services and options.
 // If usersService is nil, then there is no way to have an authenticated user.
-// Emojis image data is expected to be available at /emojis/emojis.png, unless
-// opt.DisableReactions is true.
+// Emojis image data is served by the app at {BaseURI}/assets/emojis/emojis.png,
+// unless opt.EmojisURL is set.
 //
 // In order to serve HTTP requests, the returned http.Handler expects each incoming
 // request to have 2 parameters provided to it via RepoSpecContextKey and BaseURIContextKey
@@ -59,7 +54,10 @@ import (
 // 		issuesApp.ServeHTTP(w, req)
 // 	})
 //
-// An HTTP API must be available (currently, only EditComment endpoint is used):
+// NewRouter can be used to provide them for repositories
+// served at URL paths that match a pattern.
+//
+// An HTTP API must be available (currently, only EditComment and ListComments endpoints are used):
 //
 // 	// Register HTTP API endpoints.
 // 	apiHandler := httphandler.Issues{Issues: service}
@@ -69,22 +67,29 @@ import (
 // 	http.Handle(httproute.ListEvents, errorHandler(apiHandler.ListEvents))
 // 	http.Handle(httproute.EditComment, errorHandler(apiHandler.EditComment))
 func New(service issues.Service, users users.Service, opt Options) http.Handler {
-	static, err := loadTemplates(common.State{}, opt.BodyPre)
+	h := newHandler(service, users, opt)
+	return &errorHandler{
+		handler: h.ServeHTTP,
+		users:   users,
+	}
+}
+
+func newHandler(service issues.Service, users users.Service, opt Options) *handler {
+	static, err := loadTemplates(common.State{}, opt.BodyPre, markdownRenderer{})
 	if err != nil {
 		log.Fatalln("loadTemplates failed:", err)
 	}
-	h := handler{
+	return &handler{
 		is:               service,
 		us:               users,
 		static:           static,
+		started:          time.Now(),
+		id:               lastHandlerID.Add(1),
 		assetsFileServer: httpgzip.FileServer(assets.Assets, httpgzip.FileServerOptions{ServeError: httpgzip.Detailed}),
 		gfmFileServer:    httpgzip.FileServer(assets.GFMStyle, httpgzip.FileServerOptions{ServeError: httpgzip.Detailed}),
+		emojisFileServer: httpgzip.FileServer(emojis.Assets, httpgzip.FileServerOptions{ServeError: httpgzip.Detailed}),
 		Options:          opt,
 	}
-	return &errorHandler{
-		handler: h.ServeHTTP,
-		users:   users,
-	}
 }
 
 // RepoSpecContextKey is a context key for the request's repository specification.
@@ -110,12 +115,74 @@ type Options struct {
 	HeadPre, HeadPost template.HTML
 	BodyPre           string // An html/template definition of "body-pre" template.
 
+	// EmojisURL is the URL of a directory with emojis image data, i.e., "emojis.png"
+	// of github.com/shurcooL/reactions/emojis. It's for hosts that serve it once for
+	// multiple apps. If empty, the app serves it at {BaseURI}/assets/emojis/.
+	EmojisURL string
+
 	// BodyTop provides components to include on top of <body> of page rendered for req. It can be nil.
 	// StateContextKey can be used to get the common state value.
 	BodyTop func(req *http.Request) ([]htmlg.Component, error)
 
 	// SignIn returns HTML with a link or button to sign in. It can be nil.
 	SignIn func(returnURL string) template.HTML
+
+	// ServerPreview makes Markdown previews be rendered by the server, via the "/markdown"
+	// endpoint, rather than in the browser. Previews then match rendered comments exactly.
+	// The frontend script includes a Markdown renderer for when this is false,
+	// unless it's built with the serverpreview build tag (see package frontend).
+	ServerPreview bool
+
+	// RenderMarkdown renders a Markdown body of a comment in repo as HTML. It can be nil,
+	// in which case GitHub Flavored Markdown is used. The returned HTML must be sanitized.
+	// Emoji, @mentions and issue references are linked in its output afterwards.
+	// If set, Markdown previews are rendered by the server, as with ServerPreview.
+	// Its output must depend only on repo and body, not on ctx, since it's cached
+	// in MarkdownCache for all users and requests.
+	RenderMarkdown func(ctx context.Context, repo issues.RepoSpec, body string) template.HTML
+
+	// MarkdownCache caches rendered Markdown comment bodies. It can be nil,
+	// in which case they're rendered on every request. Use NewMarkdownCache
+	// to create one with a size limit. Its Stats method reports hits and misses.
+	MarkdownCache *MarkdownCache
+
+	// Mentioned is called after an issue or comment that @mentions users is created,
+	// with the mentioned users. It can be nil. It can be used to notify mentioned users.
+	Mentioned func(ctx context.Context, repo issues.RepoSpec, issueID, commentID uint64, mentioned []users.User)
+
+	// FetchBodies makes issue lists fetch the bodies of issues that have none,
+	// so that their task progress can be displayed. Set it if the issues service
+	// doesn't include bodies in issues.Service.List, like the fs service.
+	// It costs up to 20 concurrent ListComments calls per list of issues.
+	FetchBodies bool
+
+	// IssueTemplates contains templates for new issues, such as bug reports.
+	// It can be nil. Templates of a repository are Markdown files with YAML front
+	// matter, in the directory named by its URI, e.g., "/example.org/project/bug.md".
+	// The front matter can set name, about, title (a title prefix) and labels.
+	// When there's more than one, "/new" offers a chooser, and "/new?template=bug"
+	// starts the editor with the template.
+	IssueTemplates http.FileSystem
+
+	// Pins stores which issues are pinned, unless the issues service implements
+	// Pinner itself. It can be nil, in which case issues can only be pinned
+	// if the issues service implements Pinner.
+	Pins Pinner
+
+	// Locks stores which conversations are locked, unless the issues service
+	// implements Locker itself. It can be nil, in which case conversations can
+	// only be locked if the issues service implements Locker.
+	// The HTTP API should use the same store, via httphandler.Issues.Locks.
+	Locks LockStore
+
+	// MaxPinned is the maximum number of pinned issues per repository.
+	// If zero, DefaultMaxPinned is used.
+	MaxPinned int
+
+	// Policy decides what users can do. It's consulted before rendering controls
+	// and before making changes. It can be nil, in which case policy.SignedIn is used.
+	// The HTTP API should use the same policy, via httphandler.Issues.Policy.
+	Policy policy.Policy
 }
 
 // handler handles all requests to issuesapp. It acts like a request multiplexer,
@@ -126,13 +193,25 @@ type handler struct {
 
 	assetsFileServer http.Handler
 	gfmFileServer    http.Handler
+	emojisFileServer http.Handler
 
 	// static is loaded once in New, and is only for rendering templates that don't use state.
 	static *template.Template
 
+	// started is when the app was created. It's part of the validators of rendered pages,
+	// so that pages rendered by a previous version of the app aren't reused.
+	started time.Time
+
+	// id identifies the app, so that bodies rendered by its Options.RenderMarkdown
+	// aren't reused by other apps that share its MarkdownCache.
+	id uint64
+
 	Options
 }
 
+// lastHandlerID is the id of the most recently created handler.
+var lastHandlerID atomic.Uint64
+
 func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
 	if _, ok := req.Context().Value(RepoSpecContextKey).(issues.RepoSpec); !ok {
 		return fmt.Errorf("request to %v doesn't have issuesapp.RepoSpecContextKey context key set", req.URL.Path)
@@ -141,29 +220,24 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
 		return fmt.Errorf("request to %v doesn't have issuesapp.BaseURIContextKey context key set", req.URL.Path)
 	}
 
-	// Handle "/assets/gfm/...".
-	if strings.HasPrefix(req.URL.Path, "/assets/gfm/") {
-		req = stripPrefix(req, len("/assets/gfm"))
-		h.gfmFileServer.ServeHTTP(w, req)
+	// Handle "/assets/...".
+	if h.serveAssets(w, req) {
 		return nil
 	}
 
-	// Handle "/assets/script.js".
-	if req.URL.Path == "/assets/script.js" {
-		req = stripPrefix(req, len("/assets"))
-		h.assetsFileServer.ServeHTTP(w, req)
-		return nil
+	// Handle "/".
+	if req.URL.Path == "/" {
+		return h.IssuesHandler(w, req)
 	}
 
-	// Handle (the rest of) "/assets/...".
-	if strings.HasPrefix(req.URL.Path, "/assets/") {
-		h.assetsFileServer.ServeHTTP(w, req)
-		return nil
+	// Handle "/markdown".
+	if req.URL.Path == "/markdown" {
+		return h.MarkdownHandler(w, req)
 	}
 
-	// Handle "/".
-	if req.URL.Path == "/" {
-		return h.IssuesHandler(w, req)
+	// Handle "/mentions".
+	if req.URL.Path == "/mentions" {
+		return h.MentionsHandler(w, req)
 	}
 
 	// Handle "/new".
@@ -171,6 +245,11 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
 		return h.serveNewIssue(w, req)
 	}
 
+	// Handle "/bulk".
+	if req.URL.Path == "/bulk" {
+		return h.PostBulkHandler(w, req)
+	}
+
 	// Handle "/{issueID}" and "/{issueID}/...".
 	elems := strings.SplitN(req.URL.Path[1:], "/", 3)
 	issueID, err := strconv.ParseUint(elems[0], 10, 64)
@@ -190,11 +269,51 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
 	case len(elems) == 2 && elems[1] == "comment":
 		return h.PostCommentHandler(w, req, issueID)
 
+	// "/{issueID}/lock".
+	case len(elems) == 2 && elems[1] == "lock":
+		return h.PostLockHandler(w, req, issueID)
+
+	// "/{issueID}/pin".
+	case len(elems) == 2 && elems[1] == "pin":
+		return h.PostPinHandler(w, req, issueID)
+
 	default:
 		return httperror.HTTP{Code: http.StatusNotFound, Err: errors.New("no route")}
 	}
 }
 
+// serveAssets serves req if it's a request for assets, i.e., "/assets/...",
+// and reports whether it did.
+func (h *handler) serveAssets(w http.ResponseWriter, req *http.Request) bool {
+	switch {
+	// "/assets/gfm/...".
+	case strings.HasPrefix(req.URL.Path, "/assets/gfm/"):
+		req = stripPrefix(req, len("/assets/gfm"))
+		h.gfmFileServer.ServeHTTP(w, req)
+		return true
+
+	// "/assets/emojis/...".
+	case strings.HasPrefix(req.URL.Path, "/assets/emojis/"):
+		req = stripPrefix(req, len("/assets/emojis"))
+		h.emojisFileServer.ServeHTTP(w, req)
+		return true
+
+	// "/assets/script.js".
+	case req.URL.Path == "/assets/script.js":
+		req = stripPrefix(req, len("/assets"))
+		h.assetsFileServer.ServeHTTP(w, req)
+		return true
+
+	// (The rest of) "/assets/...".
+	case strings.HasPrefix(req.URL.Path, "/assets/"):
+		h.assetsFileServer.ServeHTTP(w, req)
+		return true
+
+	default:
+		return false
+	}
+}
+
 func (h *handler) IssuesHandler(w http.ResponseWriter, req *http.Request) error {
 	if req.Method != http.MethodGet {
 		return httperror.Method{Allowed: []string{http.MethodGet}}
@@ -211,6 +330,9 @@ func (h *handler) IssuesHandler(w http.ResponseWriter, req *http.Request) error
 	if err != nil {
 		return err
 	}
+	if h.FetchBodies {
+		fillBodies(req.Context(), h.is, state.RepoSpec, is)
+	}
 	openCount, err := h.is.Count(req.Context(), state.RepoSpec, issues.IssueListOptions{State: issues.StateFilter(issues.OpenState)})
 	if err != nil {
 		return fmt.Errorf("issues.Count(open): %v", err)
@@ -219,11 +341,22 @@ func (h *handler) IssuesHandler(w http.ResponseWriter, req *http.Request) error
 	if err != nil {
 		return fmt.Errorf("issues.Count(closed): %v", err)
 	}
+	pinned, err := h.pinnedIssues(req.Context(), state.RepoSpec)
+	if err != nil {
+		return err
+	}
 	var es []component.IssueEntry
 	for _, i := range is {
-		es = append(es, component.IssueEntry{Issue: i, BaseURI: state.BaseURI})
+		selectable := state.CurrentUser.ID != 0 && h.policy().CanChangeState(req.Context(), state.RepoSpec, i, state.CurrentUser)
+		state.CanTriage = state.CanTriage || selectable
+		es = append(es, component.IssueEntry{Issue: i, Selectable: selectable, BaseURI: state.BaseURI})
 	}
+	_, state.CanLabel = h.is.(Labeler)
+	_, state.CanMilestone = h.is.(Milestoner)
 	es = state.augmentUnread(req.Context(), es, h.is, h.Notifications)
+	// Users who aren't signed in are shown the button too, so they know it's possible.
+	state.CanCreateIssue = !state.DisableUsers &&
+		(state.CurrentUser.ID == 0 || h.policy().CanCreateIssue(req.Context(), state.RepoSpec, state.CurrentUser))
 	state.Issues = component.Issues{
 		IssuesNav: component.IssuesNav{
 			OpenCount:     openCount,
@@ -235,6 +368,11 @@ func (h *handler) IssuesHandler(w http.ResponseWriter, req *http.Request) error
 		Filter:  filter,
 		Entries: es,
 	}
+	state.PinnedIssues = component.PinnedIssues{Issues: pinned, BaseURI: state.BaseURI}
+	v := h.validator(&state)
+	if v.CheckNotModified(w, req) {
+		return nil
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	err = h.static.ExecuteTemplate(w, "issues.html.tmpl", &state)
 	if err != nil {
@@ -347,13 +485,61 @@ func (h *handler) IssueHandler(w http.ResponseWriter, req *http.Request, issueID
 		}
 		sort.Sort(byCreatedAtID(items))
 	}
+	crossReferences, err := h.crossReferenceItems(req.Context(), state.RepoSpec, state.IssueID)
+	if err != nil {
+		return fmt.Errorf("crossReferenceItems: %v", err)
+	}
+	lockEvents, err := h.lockEventItems(req.Context(), state.RepoSpec, state.IssueID)
+	if err != nil {
+		return err
+	}
+	if len(crossReferences) > 0 || len(lockEvents) > 0 {
+		items = append(items, crossReferences...)
+		items = append(items, lockEvents...)
+		sort.Sort(byCreatedAtID(items))
+	}
 	state.Items = items
+	err = h.applyPolicy(req.Context(), &state)
+	if err != nil {
+		return err
+	}
+	var participants []users.User
+	for _, item := range items {
+		if c, ok := item.IssueItem.(issues.Comment); ok {
+			participants = appendUser(participants, c.User)
+		}
+	}
+	md := h.markdownRenderer(req.Context(), state.RepoSpec, state.BaseURI, participants)
+	references := mayReference(items)
+	var resolved []interface{}
+	if references {
+		md = md.recording(&resolved)
+	}
 	// Call loadTemplates to set updated reactionsBar, reactableID, etc., template functions.
-	t, err := loadTemplates(state.State, h.Options.BodyPre)
+	t, err := loadTemplates(state.State, h.Options.BodyPre, md)
 	if err != nil {
 		return fmt.Errorf("loadTemplates: %v", err)
 	}
+	var page bytes.Buffer
+	if references {
+		// Referenced issues and @mentioned users are displayed as they are now,
+		// and can change without the comments changing. So render the page first,
+		// to find out what they resolve to, and make that part of the validators.
+		err = t.ExecuteTemplate(&page, "issue.html.tmpl", &state)
+		if err != nil {
+			return fmt.Errorf("t.ExecuteTemplate: %v", err)
+		}
+	}
+	v := h.validator(&state)
+	v.Add(resolved)
+	if v.CheckNotModified(w, req) {
+		return nil
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if references {
+		_, err = io.Copy(w, &page)
+		return err
+	}
 	err = t.ExecuteTemplate(w, "issue.html.tmpl", &state)
 	if err != nil {
 		return fmt.Errorf("t.ExecuteTemplate: %v", err)
@@ -377,9 +563,33 @@ func (h *handler) CreateIssueHandler(w http.ResponseWriter, req *http.Request) e
 	if err != nil {
 		return err
 	}
-	if state.CurrentUser.ID == 0 {
+	if !h.policy().CanCreateIssue(req.Context(), state.RepoSpec, state.CurrentUser) {
 		return os.ErrPermission
 	}
+	ts, err := h.issueTemplates(state.RepoSpec)
+	if err != nil {
+		return err
+	}
+	switch id, ok := req.URL.Query()["template"]; {
+	case ok && id[0] != "":
+		t, ok := findIssueTemplate(ts, id[0])
+		if !ok {
+			return httperror.HTTP{Code: http.StatusNotFound, Err: fmt.Errorf("issue template %q not found", id[0])}
+		}
+		state.IssueTemplate = &t
+	case ok:
+		// A blank issue.
+	case len(ts) == 1:
+		state.IssueTemplate = &ts[0]
+	case len(ts) > 1:
+		state.IssueTemplates = ts
+	}
+	if state.IssueTemplates == nil {
+		state.Labels, err = h.labelChoices(req.Context(), state.RepoSpec, state.CurrentUser, state.IssueTemplate)
+		if err != nil {
+			return err
+		}
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	err = h.static.ExecuteTemplate(w, "new-issue.html.tmpl", &state)
 	if err != nil {
@@ -392,16 +602,42 @@ func (h *handler) PostCreateIssueHandler(w http.ResponseWriter, req *http.Reques
 	repoSpec := req.Context().Value(RepoSpecContextKey).(issues.RepoSpec)
 	baseURI := req.Context().Value(BaseURIContextKey).(string)
 
+	currentUser, err := h.currentUser(req.Context())
+	if err != nil {
+		return err
+	}
+	if !h.policy().CanCreateIssue(req.Context(), repoSpec, currentUser) {
+		return os.ErrPermission
+	}
+
 	var issue issues.Issue
-	err := json.NewDecoder(req.Body).Decode(&issue)
+	err = json.NewDecoder(req.Body).Decode(&issue)
 	if err != nil {
 		return httperror.BadRequest{Err: fmt.Errorf("json.Decode: %v", err)}
 	}
+	var tmpl *issueTemplate
+	if id := req.URL.Query().Get("template"); id != "" {
+		ts, err := h.issueTemplates(repoSpec)
+		if err != nil {
+			return err
+		}
+		t, ok := findIssueTemplate(ts, id)
+		if !ok {
+			return httperror.BadRequest{Err: fmt.Errorf("issue template %q not found", id)}
+		}
+		tmpl = &t
+	}
+	issue.Labels, err = h.issueLabels(req.Context(), repoSpec, currentUser, issue.Labels, tmpl)
+	if err != nil {
+		return err
+	}
 
 	issue, err = h.is.Create(req.Context(), repoSpec, issue)
 	if err != nil {
 		return err
 	}
+	h.notifyMentioned(req.Context(), repoSpec, issue.ID, 0, issue.Body, h.mentionResolver(req.Context(), []users.User{issue.User}))
+	h.crossReference(req.Context(), repoSpec, issue.ID, issue.Comment, h.issueResolver(req.Context(), repoSpec, maxIssueLookups))
 
 	fmt.Fprintf(w, "%s/%d", baseURI, issue.ID)
 	return nil
@@ -423,6 +659,18 @@ func (h *handler) PostEditIssueHandler(w http.ResponseWriter, req *http.Request,
 		return httperror.BadRequest{Err: fmt.Errorf("json.Unmarshal 'value': %v", err)}
 	}
 
+	currentUser, err := h.currentUser(req.Context())
+	if err != nil {
+		return err
+	}
+	issue, err := h.is.Get(req.Context(), repoSpec, issueID)
+	if err != nil {
+		return err
+	}
+	if !h.policy().CanChangeState(req.Context(), repoSpec, issue, currentUser) {
+		return os.ErrPermission
+	}
+
 	issue, events, err := h.is.Edit(req.Context(), repoSpec, issueID, ir)
 	if err != nil {
 		return err
@@ -471,6 +719,17 @@ func (h *handler) PostCommentHandler(w http.ResponseWriter, req *http.Request, i
 	if err != nil {
 		return err
 	}
+	state.Issue, err = h.is.Get(req.Context(), state.RepoSpec, issueID)
+	if err != nil {
+		return err
+	}
+	err = h.applyPolicy(req.Context(), &state)
+	if err != nil {
+		return err
+	}
+	if !state.CanComment {
+		return os.ErrPermission
+	}
 
 	comment := issues.Comment{
 		Body: req.PostForm.Get("value"),
@@ -479,9 +738,17 @@ func (h *handler) PostCommentHandler(w http.ResponseWriter, req *http.Request, i
 	if err != nil {
 		return err
 	}
+	comment.Editable = comment.Editable && h.policy().CanEditComment(req.Context(), state.RepoSpec, state.Issue, comment, state.CurrentUser)
+	participants, err := h.participants(req.Context(), state.RepoSpec, issueID)
+	if err != nil {
+		return fmt.Errorf("participants: %v", err)
+	}
+	md := h.markdownRenderer(req.Context(), state.RepoSpec, state.BaseURI, participants)
+	h.notifyMentioned(req.Context(), state.RepoSpec, issueID, comment.ID, comment.Body, md.resolveUser)
+	h.crossReference(req.Context(), state.RepoSpec, issueID, comment, md.resolveIssue)
 
 	// Call loadTemplates to set updated reactionsBar, reactableID, etc., template functions.
-	t, err := loadTemplates(state.State, h.Options.BodyPre)
+	t, err := loadTemplates(state.State, h.Options.BodyPre, md)
 	if err != nil {
 		return fmt.Errorf("loadTemplates: %v", err)
 	}
@@ -493,23 +760,24 @@ func (h *handler) PostCommentHandler(w http.ResponseWriter, req *http.Request, i
 }
 
 func (h *handler) state(req *http.Request, issueID uint64) (state, error) {
-	// TODO: Caller still does a lot of work outside to calculate req.URL.Path by
-	//       subtracting BaseURI from full original req.URL.Path. We should be able
-	//       to compute it here internally by using req.RequestURI and BaseURI.
+	// req.URL.Path is relative to BaseURI. Hosts can use NewRouter
+	// to compute it by stripping BaseURI from the original URL path.
 	reqPath := req.URL.Path
 	if reqPath == "/" {
 		reqPath = "" // This is needed so that absolute URL for root view, i.e., /issues, is "/issues" and not "/issues/" because of "/issues" + "/".
 	}
+	repo, _ := req.Context().Value(RepoSpecContextKey).(issues.RepoSpec) // Not set for dashboards.
 	b := state{
 		State: common.State{
 			BaseURI:  req.Context().Value(BaseURIContextKey).(string),
 			ReqPath:  reqPath,
-			RepoSpec: req.Context().Value(RepoSpecContextKey).(issues.RepoSpec),
+			RepoSpec: repo,
 			IssueID:  issueID,
 		},
 	}
 	b.HeadPre = h.HeadPre
 	b.HeadPost = h.HeadPost
+	b.EmojisURL = h.EmojisURL
 	if h.BodyTop != nil {
 		c, err := h.BodyTop(req.WithContext(context.WithValue(req.Context(), StateContextKey, b.State)))
 		if err != nil {
@@ -524,19 +792,17 @@ func (h *handler) state(req *http.Request, issueID uint64) (state, error) {
 	}
 
 	b.DisableReactions = h.Options.DisableReactions
+	b.ServerPreview = h.Options.ServerPreview || h.Options.RenderMarkdown != nil
 	b.DisableUsers = h.us == nil
 	if h.Options.SignIn != nil {
 		returnURL := b.BaseURI + b.ReqPath
 		b.SignIn = h.Options.SignIn(returnURL)
 	}
 
-	if h.us == nil {
-		// No user service provided, so there can never be an authenticated user.
-		b.CurrentUser = users.User{}
-	} else if user, err := h.us.GetAuthenticated(req.Context()); err == nil {
-		b.CurrentUser = user
-	} else {
-		return state{}, fmt.Errorf("h.us.GetAuthenticated: %v", err)
+	var err error
+	b.CurrentUser, err = h.currentUser(req.Context())
+	if err != nil {
+		return state{}, err
 	}
 
 	b.ForceIssuesApp, _ = strconv.ParseBool(req.URL.Query().Get("issuesapp"))
@@ -544,16 +810,110 @@ func (h *handler) state(req *http.Request, issueID uint64) (state, error) {
 	return b, nil
 }
 
+// currentUser returns the authenticated user,
+// or the zero value if there isn't one.
+func (h *handler) currentUser(ctx context.Context) (users.User, error) {
+	if h.us == nil {
+		// No user service provided, so there can never be an authenticated user.
+		return users.User{}, nil
+	}
+	user, err := h.us.GetAuthenticated(ctx)
+	if err != nil {
+		return users.User{}, fmt.Errorf("h.us.GetAuthenticated: %v", err)
+	}
+	return user, nil
+}
+
+// policy returns the policy that decides what users can do.
+func (h *handler) policy() policy.Policy {
+	if h.Policy == nil {
+		return policy.SignedIn{}
+	}
+	return h.Policy
+}
+
+// applyPolicy sets what the current user can do with s.Issue in s,
+// and makes comments in s.Items that they can't edit not editable.
+// While the conversation is locked, only users who can lock it
+// can comment and react.
+func (h *handler) applyPolicy(ctx context.Context, s *state) error {
+	locked, err := h.isLocked(ctx, s.RepoSpec, s.Issue.ID)
+	if err != nil {
+		return err
+	}
+	lockable := h.locker() != nil
+	p := h.policy()
+	canLock := p.CanLock(ctx, s.RepoSpec, s.Issue, s.CurrentUser)
+	s.Locked = locked
+	s.CanLock = lockable && canLock
+	s.CanComment = p.CanComment(ctx, s.RepoSpec, s.Issue, s.CurrentUser) && (!locked || canLock)
+	s.CanChangeState = s.Issue.Editable && p.CanChangeState(ctx, s.RepoSpec, s.Issue, s.CurrentUser)
+	s.CanReact = p.CanReact(ctx, s.RepoSpec, s.Issue, s.CurrentUser) && (!locked || canLock)
+	if h.pinner() != nil && p.CanPin(ctx, s.RepoSpec, s.Issue, s.CurrentUser) {
+		pinned, err := h.listPinned(ctx, s.RepoSpec)
+		if err != nil {
+			return err
+		}
+		s.Pinned = containsID(pinned, s.Issue.ID)
+		s.CanPin = true
+	}
+	for i, item := range s.Items {
+		if c, ok := item.IssueItem.(issues.Comment); ok && c.Editable {
+			c.Editable = p.CanEditComment(ctx, s.RepoSpec, s.Issue, c, s.CurrentUser)
+			s.Items[i].IssueItem = c
+		}
+	}
+	return nil
+}
+
+// validator returns a validator of a page rendered from s.
+// Pages depend on the current user and on data that can change without
+// a new modification time, so they only have an entity tag.
+func (h *handler) validator(s *state) *common.Validator {
+	v := new(common.Validator)
+	v.Add(h.started)
+	v.Add(s)
+	return v
+}
+
 type state struct {
 	HeadPre, HeadPost template.HTML
 	BodyTop           template.HTML
 	SignIn            template.HTML
+	EmojisURL         string // URL of emojis image data, if not served by the app.
 
 	common.State
 
-	Issues component.Issues
-	Issue  issues.Issue
-	Items  []issueItem
+	Issues       component.Issues
+	PinnedIssues component.PinnedIssues
+	Issue        issues.Issue
+	Items        []issueItem
+
+	// IssueTemplates are offered in a chooser on the new issue page,
+	// and IssueTemplate is the one its editor starts with, if any.
+	IssueTemplates []issueTemplate
+	IssueTemplate  *issueTemplate
+
+	// Labels are offered in the label selector of the new issue page.
+	Labels []labelChoice
+
+	// Locked is whether the conversation of Issue is locked.
+	Locked bool
+
+	// Pinned is whether Issue is pinned. It's only known if CanPin.
+	Pinned bool
+
+	// What the current user can do, as decided by the policy.
+	CanCreateIssue bool
+	CanComment     bool
+	CanChangeState bool
+	CanLock        bool // CanLock is whether they can lock or unlock the conversation, if the issues service supports it.
+	CanPin         bool // CanPin is whether they can pin or unpin Issue, if there's a Pinner.
+	CanTriage      bool // CanTriage is whether they can apply bulk actions to some of the listed issues.
+
+	// Bulk actions supported by the issues service, besides closing and reopening.
+	CanLabel     bool
+	CanMilestone bool
 
 	// ForceIssuesApp reports whether "issuesapp" query is true.
 	// This is a temporary solution for external users to use when overriding templates.
@@ -561,7 +921,7 @@ type state struct {
 	ForceIssuesApp bool
 }
 
-func loadTemplates(state common.State, bodyPre string) (*template.Template, error) {
+func loadTemplates(state common.State, bodyPre string, md markdownRenderer) (*template.Template, error) {
 	t := template.New("").Funcs(template.FuncMap{
 		"json": func(v interface{}) (string, error) {
 			b, err := json.Marshal(v)
@@ -572,7 +932,8 @@ func loadTemplates(state common.State, bodyPre string) (*template.Template, erro
 			return string(b), err
 		},
 		"reltime":          humanize.Time,
-		"gfm":              func(s string) template.HTML { return template.HTML(github_flavored_markdown.Markdown([]byte(s))) },
+		"gfm":              md.Render,
+		"gfmEditable":      md.RenderEditable,
 		"reactionPosition": func(emojiID reactions.EmojiID) string { return reactions.Position(":" + string(emojiID) + ":") },
 		"equalUsers": func(a, b users.User) bool {
 			return a.UserSpec == b.UserSpec
@@ -610,8 +971,12 @@ func loadTemplates(state common.State, bodyPre string) (*template.Template, erro
 		"render": func(c htmlg.Component) template.HTML {
 			return template.HTML(htmlg.Render(c.Render()...))
 		},
-		"event":           func(e issues.Event) htmlg.Component { return component.Event{Event: e} },
+		"event": func(e issues.Event) htmlg.Component { return component.Event{Event: e} },
+		"crossReference": func(r crossReferenceItem) htmlg.Component {
+			return component.CrossReferenceEvent{Actor: r.Actor, CreatedAt: r.CreatedAt, Source: r.Source, BaseURI: state.BaseURI}
+		},
 		"issueStateBadge": func(i issues.Issue) htmlg.Component { return component.IssueStateBadge{Issue: i} },
+		"label":           func(l issues.Label) htmlg.Component { return component.Label{Label: l} },
 		"time":            func(t time.Time) htmlg.Component { return component.Time{Time: t} },
 		"user":            func(u users.User) htmlg.Component { return component.User{User: u} },
 		"avatar":          func(u users.User) htmlg.Component { return component.Avatar{User: u, Size: 48} },
@@ -3,14 +3,22 @@ package issuesapp_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shurcooL/issues"
 	"github.com/shurcooL/issues/fs"
 	"github.com/shurcooL/issuesapp"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/issuesapp/policy"
 	"github.com/shurcooL/reactions"
 	"github.com/shurcooL/users"
 	"github.com/shurcooL/webdavfs/vfsutil"
@@ -37,11 +45,18 @@ func TestRoutes(t *testing.T) {
 		{"GET", "/assets/script.js", http.StatusOK},
 		{"POST", "/assets/script.js", http.StatusMethodNotAllowed},
 		{"GET", "/assets/gfm/gfm.css", http.StatusOK},
+		{"GET", "/assets/emojis/emojis.png", http.StatusOK},
 
 		{"GET", "/", http.StatusOK},
 		{"POST", "/", http.StatusMethodNotAllowed},
 		{"GET", "/new", http.StatusOK},
 		{"PATCH", "/new", http.StatusMethodNotAllowed},
+		{"GET", "/markdown", http.StatusMethodNotAllowed},
+		{"POST", "/markdown", http.StatusOK},
+		{"GET", "/mentions?q=go", http.StatusOK},
+		{"GET", "/mentions?q=go&issue=1", http.StatusOK},
+		{"GET", "/mentions?issue=foobar", http.StatusBadRequest},
+		{"POST", "/mentions", http.StatusMethodNotAllowed},
 		{"GET", "/1", http.StatusOK},
 		{"POST", "/1", http.StatusMethodNotAllowed},
 		{"GET", "/1/", http.StatusNotFound},
@@ -64,7 +79,637 @@ func TestRoutes(t *testing.T) {
 	}
 }
 
+func TestSignedInOnly(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuesApp := issuesapp.New(service, anonymousUsers{}, issuesapp.Options{})
+
+	for _, tc := range []struct{ method, url string }{
+		{"GET", "/mentions?q=go"},
+		{"POST", "/markdown"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		if got, want := w.Code, http.StatusForbidden; got != want {
+			t.Errorf("%s %q when not signed in: got %v, want %v", tc.method, tc.url, http.StatusText(got), http.StatusText(want))
+		}
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	issuesApp, err := mockIssuesApp(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+	getSince := func(url string, ifModifiedSince time.Time) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, url := range []string{"/", "/1"} {
+		w := get(url, "")
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" {
+			t.Fatalf("GET %q: got %v with ETag %q, want OK with an ETag", url, http.StatusText(w.Code), etag)
+		}
+		// Pages depend on the current user and can change without a new modification time.
+		if got := w.Header().Get("Last-Modified"); got != "" {
+			t.Errorf("GET %q: got Last-Modified %q, want none", url, got)
+		}
+		if got, want := w.Header().Get("Cache-Control"), "private, no-cache"; got != want {
+			t.Errorf("GET %q: got Cache-Control %q, want %q", url, got, want)
+		}
+		if got, want := getSince(url, time.Now()).Code, http.StatusOK; got != want {
+			t.Errorf("GET %q with If-Modified-Since: got %v, want %v", url, http.StatusText(got), http.StatusText(want))
+		}
+		if got, want := get(url, etag).Code, http.StatusNotModified; got != want {
+			t.Errorf("GET %q with matching If-None-Match: got %v, want %v", url, http.StatusText(got), http.StatusText(want))
+		}
+		if got, want := get(url, `W/"stale"`).Code, http.StatusOK; got != want {
+			t.Errorf("GET %q with stale If-None-Match: got %v, want %v", url, http.StatusText(got), http.StatusText(want))
+		}
+	}
+}
+
+func TestConditionalGetReferences(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	referencing, err := service.Create(context.Background(), repo, issues.Issue{
+		Title:   "Some issue that references another",
+		Comment: issues.Comment{Body: "See #1."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{})
+	url := fmt.Sprintf("/%d", referencing.ID)
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+
+	etag := get("").Header().Get("ETag")
+	if got, want := get(etag).Code, http.StatusNotModified; got != want {
+		t.Errorf("GET %q with matching If-None-Match: got %v, want %v", url, http.StatusText(got), http.StatusText(want))
+	}
+	// Renaming the referenced issue changes the page, though the referencing one didn't change.
+	title := "Renamed issue"
+	_, _, err = service.Edit(context.Background(), repo, 1, issues.IssueRequest{Title: &title})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := get(etag)
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("GET %q after the referenced issue changed: got %v, want %v", url, http.StatusText(got), http.StatusText(want))
+	}
+	if !strings.Contains(w.Body.String(), title) {
+		t.Errorf("GET %q: response doesn't contain the referenced issue's title %q", url, title)
+	}
+}
+
+func TestTaskProgress(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fs service doesn't include bodies in List, so they need to be fetched.
+	_, err = service.Create(context.Background(), repo, issues.Issue{
+		Title:   "Some issue with tasks",
+		Comment: issues.Comment{Body: "- [x] First task.\n- [ ] Second task."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `title="1 of 2 tasks completed"`
+	for _, fetchBodies := range []bool{false, true} {
+		issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{FetchBodies: fetchBodies})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /: got %v, want OK", http.StatusText(w.Code))
+		}
+		if got := strings.Contains(w.Body.String(), want); got != fetchBodies {
+			t.Errorf("GET / with FetchBodies %v: response contains task progress %q: %v, want %v", fetchBodies, want, got, fetchBodies)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(p policy.Policy, method, url, body string) *httptest.ResponseRecorder {
+		issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{Policy: p})
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+
+	// The signed in user can only read.
+	none := policy.Roles{Default: policy.None}
+	if w := do(none, "GET", "/1", ""); strings.Contains(w.Body.String(), "new-comment-container") {
+		t.Error("GET /1: got a new comment form, want none")
+	}
+	for _, tc := range []struct{ method, url, body string }{
+		{"GET", "/new", ""},
+		{"POST", "/new", `{"Title": "New issue"}`},
+		{"POST", "/1/comment", "value=Hello."},
+		{"POST", "/1/edit", `value={"State": "closed"}`},
+	} {
+		if got, want := do(none, tc.method, tc.url, tc.body).Code, http.StatusForbidden; got != want {
+			t.Errorf("%s %q: got %v, want %v", tc.method, tc.url, http.StatusText(got), http.StatusText(want))
+		}
+	}
+
+	// The signed in user is the author of issue 1, so a reader can close it.
+	reader := policy.Roles{Default: policy.Reader}
+	body := do(reader, "GET", "/1", "").Body.String()
+	if !strings.Contains(body, "new-comment-container") || !strings.Contains(body, "issue-toggle-button") {
+		t.Error("GET /1: got no new comment form or toggle button, want both")
+	}
+	if got, want := do(reader, "POST", "/1/comment", "value=Hello.").Code, http.StatusOK; got != want {
+		t.Errorf("POST /1/comment: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+}
+
+func TestLock(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locker := &mockLocker{Service: service, locked: make(map[uint64]bool), events: make(map[uint64][]issues.Event)}
+	do := func(p policy.Policy, method, url, body string) *httptest.ResponseRecorder {
+		issuesApp := issuesapp.New(locker, mockUsers{}, issuesapp.Options{Policy: p})
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+	reader := policy.Roles{Default: policy.Reader}
+	maintainer := policy.Roles{Default: policy.Maintainer}
+
+	if got, want := do(reader, "POST", "/1/lock", "locked=true").Code, http.StatusForbidden; got != want {
+		t.Errorf("POST /1/lock as reader: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	if got, want := do(maintainer, "POST", "/1/lock", "locked=true").Code, http.StatusSeeOther; got != want {
+		t.Errorf("POST /1/lock as maintainer: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+
+	body := do(reader, "GET", "/1", "").Body.String()
+	if !strings.Contains(body, "This conversation has been locked") || strings.Contains(body, "new-comment-container") {
+		t.Error("GET /1 as reader of locked issue: got no locked notice or a new comment form, want only the notice")
+	}
+	if !strings.Contains(body, "locked this conversation") {
+		t.Error("GET /1: got no locked event, want one")
+	}
+	if got, want := do(reader, "POST", "/1/comment", "value=Hello.").Code, http.StatusForbidden; got != want {
+		t.Errorf("POST /1/comment as reader of locked issue: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	if got, want := do(maintainer, "POST", "/1/comment", "value=Hello.").Code, http.StatusOK; got != want {
+		t.Errorf("POST /1/comment as maintainer of locked issue: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+}
+
+func TestLockStore(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locks := &mockLocks{locked: make(map[uint64]bool), events: make(map[uint64][]issues.Event)}
+	do := func(p policy.Policy, method, url, body string) *httptest.ResponseRecorder {
+		issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{Locks: locks, Policy: p})
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+	reader := policy.Roles{Default: policy.Reader}
+	maintainer := policy.Roles{Default: policy.Maintainer}
+
+	for i := 0; i < 2; i++ {
+		if got, want := do(maintainer, "POST", "/1/lock", "locked=true").Code, http.StatusSeeOther; got != want {
+			t.Errorf("POST /1/lock as maintainer: got %v, want %v", http.StatusText(got), http.StatusText(want))
+		}
+	}
+	// Locking a locked conversation again doesn't record another event.
+	if got, want := len(locks.events[1]), 1; got != want {
+		t.Errorf("got %d lock events, want %d", got, want)
+	}
+	// Lock events of the store are shown, since the issues service doesn't list them.
+	body := do(reader, "GET", "/1", "").Body.String()
+	if !strings.Contains(body, "This conversation has been locked") || !strings.Contains(body, "locked this conversation") {
+		t.Error("GET /1 as reader of locked issue: got no locked notice or locked event, want both")
+	}
+	if got, want := do(reader, "POST", "/1/comment", "value=Hello.").Code, http.StatusForbidden; got != want {
+		t.Errorf("POST /1/comment as reader of locked issue: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+}
+
+func TestPins(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins := mockPins{}
+	do := func(p policy.Policy, maxPinned int, method, url, body string) *httptest.ResponseRecorder {
+		issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{Pins: pins, MaxPinned: maxPinned, Policy: p})
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+	reader := policy.Roles{Default: policy.Reader}
+	maintainer := policy.Roles{Default: policy.Maintainer}
+
+	if got, want := do(reader, 0, "POST", "/1/pin", "pinned=true").Code, http.StatusForbidden; got != want {
+		t.Errorf("POST /1/pin as reader: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	if got, want := do(maintainer, 0, "POST", "/1/pin", "pinned=true").Code, http.StatusSeeOther; got != want {
+		t.Errorf("POST /1/pin as maintainer: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+
+	// Pinned issues are shown regardless of the filter.
+	if body := do(reader, 0, "GET", "/?state=closed", "").Body.String(); !strings.Contains(body, "pinned-issue") || !strings.Contains(body, "Some issue about something") {
+		t.Error("GET /?state=closed: got no pinned issue, want one")
+	}
+
+	// Pinning more than MaxPinned issues fails. Pinned issues that
+	// don't exist count towards it, but aren't shown.
+	pins[repo.URI] = []uint64{99}
+	if got, want := do(maintainer, 1, "POST", "/1/pin", "pinned=true").Code, http.StatusConflict; got != want {
+		t.Errorf("POST /1/pin over the limit: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	if body := do(reader, 0, "GET", "/", "").Body.String(); strings.Contains(body, "pinned-issue") {
+		t.Error("GET /: got a pinned issue, want none")
+	}
+}
+
+func TestBulk(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{})
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+
+	if body := do("GET", "/", "").Body.String(); !strings.Contains(body, `class="bulk-select"`) || !strings.Contains(body, `id="bulk-triage"`) {
+		t.Error("GET /: got no issue checkboxes or bulk action bar, want both")
+	}
+	if got, want := do("POST", "/bulk", "issue=1&action=add-label&label=bug").Code, http.StatusBadRequest; got != want {
+		t.Errorf("POST /bulk add-label without a Labeler: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+
+	w := do("POST", "/bulk", "issue=1&issue=2&action=close")
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("POST /bulk: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	if body := w.Body.String(); strings.Count(body, "bulk-success") != 1 || !strings.Contains(body, "Issue not found.") {
+		t.Errorf("POST /bulk: got body without one success and one failure:\n%s", body)
+	}
+	issue, err := service.Get(context.Background(), repo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.State != issues.ClosedState {
+		t.Errorf("issue 1: got state %v, want %v", issue.State, issues.ClosedState)
+	}
+
+	bulk := func(s issues.Service, p policy.Policy, body string) {
+		req := httptest.NewRequest("POST", "/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		issuesapp.New(s, mockUsers{}, issuesapp.Options{Policy: p}).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Only users who can apply a label can remove it.
+	labeler := &mockLabeler{Service: service}
+	for _, tc := range []struct {
+		name        string
+		p           policy.Policy
+		wantRemoved int
+	}{
+		{"reader", policy.Roles{Default: policy.Reader}, 0},
+		{"maintainer", policy.Roles{Default: policy.Maintainer}, 1},
+	} {
+		bulk(labeler, tc.p, "issue=1&action=remove-label&label=label")
+		if labeler.removed != tc.wantRemoved {
+			t.Errorf("POST /bulk remove-label as %s: got %d labels removed, want %d", tc.name, labeler.removed, tc.wantRemoved)
+		}
+	}
+
+	// Only triagers can set milestones, and only of issues they can edit.
+	for _, tc := range []struct {
+		name       string
+		p          policy.Policy
+		uneditable bool
+		wantSet    int
+	}{
+		{"reader", policy.Roles{Default: policy.Reader}, false, 0},
+		{"triager", policy.Roles{Default: policy.Triager}, false, 1},
+		{"triager of an uneditable issue", policy.Roles{Default: policy.Triager}, true, 0},
+	} {
+		milestoner := &mockMilestoner{Service: service, uneditable: tc.uneditable}
+		bulk(milestoner, tc.p, "issue=1&action=set-milestone&milestone=v1")
+		if milestoner.set != tc.wantSet {
+			t.Errorf("POST /bulk set-milestone as %s: got %d milestones set, want %d", tc.name, milestoner.set, tc.wantSet)
+		}
+	}
+}
+
+func TestIssueTemplates(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "issuesapp_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = os.MkdirAll(filepath.Join(dir, repo.URI), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{
+		"bug.md":     "---\nname: Bug report\ntitle: \"bug: \"\nlabels: [bug]\n---\n### What did you do?\n",
+		"feature.md": "---\nname: Feature request\n---\n### What would you like?\n",
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, repo.URI, name), []byte(body), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{IssueTemplates: http.Dir(dir)})
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+
+	if body := do("GET", "/new", "").Body.String(); !strings.Contains(body, "Bug report") || !strings.Contains(body, "Feature request") || strings.Contains(body, "title-editor") {
+		t.Error("GET /new: got no template chooser, want one")
+	}
+	body := do("GET", "/new?template=bug", "").Body.String()
+	if !strings.Contains(body, `value="bug: "`) || !strings.Contains(body, "### What did you do?") {
+		t.Error("GET /new?template=bug: got an editor without the template, want it pre-filled")
+	}
+	if got, want := do("GET", "/new?template=nope", "").Code, http.StatusNotFound; got != want {
+		t.Errorf("GET /new?template=nope: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+
+	w := do("POST", "/new?template=bug", `{"Title": "bug: It's broken", "Body": "Yes."}`)
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("POST /new?template=bug: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	issue, err := service.Get(context.Background(), repo, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issue.Labels) != 1 || issue.Labels[0].Name != "bug" {
+		t.Errorf("created issue: got labels %v, want bug", issue.Labels)
+	}
+}
+
+func TestCreateIssueLabels(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(p policy.Policy, method, url, body string) *httptest.ResponseRecorder {
+		issuesApp := issuesapp.New(service, mockUsers{}, issuesapp.Options{Policy: p})
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		return w
+	}
+	reader := policy.Roles{Default: policy.Reader, AllowedLabels: map[string][]string{repo.URI: {"label"}}}
+	maintainer := policy.Roles{Default: policy.Maintainer}
+
+	body := do(reader, "GET", "/new", "").Body.String()
+	if !strings.Contains(body, `value="label"`) || strings.Contains(body, `value="another"`) {
+		t.Error("GET /new as reader: got a label selector without only the allowed label, want it")
+	}
+
+	if got, want := do(reader, "POST", "/new", `{"Title": "Hi", "Labels": [{"Name": "another"}]}`).Code, http.StatusForbidden; got != want {
+		t.Errorf("POST /new with a label that isn't allowed: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	if got, want := do(reader, "POST", "/new", `{"Title": "Hi", "Labels": [{"Name": "label", "Color": {"R": 255}}]}`).Code, http.StatusOK; got != want {
+		t.Errorf("POST /new with an allowed label: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+	if got, want := do(maintainer, "POST", "/new", `{"Title": "Hi", "Labels": [{"Name": "new"}]}`).Code, http.StatusOK; got != want {
+		t.Errorf("POST /new with a new label as maintainer: got %v, want %v", http.StatusText(got), http.StatusText(want))
+	}
+
+	// Labels get the colors they have in the repository.
+	issue, err := service.Get(context.Background(), repo, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []issues.Label{{Name: "label", Color: issues.RGB{R: 224, G: 235, B: 245}}}; !reflect.DeepEqual(issue.Labels, want) {
+		t.Errorf("issue 2: got labels %v, want %v", issue.Labels, want)
+	}
+}
+
+func TestDashboardRoutes(t *testing.T) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := issues.RepoSpec{URI: "example.org/other"}
+	otherService, err := mockIssuesService(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboard := issuesapp.NewDashboard(service, mockUsers{}, []issuesapp.DashboardRepo{
+		{Repo: repo, BaseURI: "/example.org"},
+		{Repo: issues.RepoSpec{URI: "example.org/empty"}, BaseURI: "/example.org/empty"},
+		{Repo: other, BaseURI: "/example.org/other", Service: otherService},
+	}, issuesapp.Options{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "/dashboard"))
+		dashboard.ServeHTTP(w, req)
+	})
+
+	tests := []struct {
+		method, url string
+		wantCode    int
+	}{
+		{"GET", "/assets/style.css", http.StatusOK},
+		{"GET", "/", http.StatusOK},
+		{"GET", "/?state=all", http.StatusOK},
+		{"GET", "/?repo=example.org", http.StatusOK},
+		{"GET", "/?repo=example.org/foobar", http.StatusBadRequest},
+		{"GET", "/?state=foobar", http.StatusBadRequest},
+		{"POST", "/", http.StatusMethodNotAllowed},
+		{"GET", "/1", http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if got, want := w.Code, tc.wantCode; got != want {
+			t.Errorf("%s %q: got %v, want %v", tc.method, tc.url, http.StatusText(got), http.StatusText(want))
+		}
+	}
+
+	// Issues of a repository with its own service are listed from that service.
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/?repo=example.org/other", nil))
+	if want := `href="/example.org/other/1"`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("GET %q: response doesn't contain link %q", "/?repo=example.org/other", want)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	issuesApp := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		repo := req.Context().Value(issuesapp.RepoSpecContextKey).(issues.RepoSpec)
+		baseURI := req.Context().Value(issuesapp.BaseURIContextKey).(string)
+		fmt.Fprintf(w, "%s %s %s", repo.URI, baseURI, req.URL.Path)
+	})
+	known := func(_ context.Context, repo issues.RepoSpec) bool { return repo.URI != "example.org/unknown/repo" }
+	router := issuesapp.NewRouter(issuesApp, issuesapp.PatternResolver("/{host}/{owner}/{repo}/issues", known))
+
+	tests := []struct {
+		url      string
+		wantCode int
+		want     string // Response body, or Location header of a redirect.
+	}{
+		{"/example.org/owner/repo/issues", http.StatusOK, "example.org/owner/repo /example.org/owner/repo/issues /"},
+		{"/example.org/owner/repo/issues/1", http.StatusOK, "example.org/owner/repo /example.org/owner/repo/issues /1"},
+		{"/example.org/owner/repo/issues/1/comment", http.StatusOK, "example.org/owner/repo /example.org/owner/repo/issues /1/comment"},
+		{"/example.org/owner/repo/issues/", http.StatusFound, "/example.org/owner/repo/issues"},
+		{"/example.org/owner/repo/issues/?state=closed", http.StatusFound, "/example.org/owner/repo/issues?state=closed"},
+		{"/example.org/owner/repo/issuesfoo", http.StatusNotFound, ""},
+		{"/example.org/owner/repo", http.StatusNotFound, ""},
+		{"/example.org//repo/issues", http.StatusNotFound, ""},
+		{"/example.org/unknown/repo/issues", http.StatusNotFound, ""},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", tc.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if got, want := w.Code, tc.wantCode; got != want {
+			t.Errorf("GET %q: got %v, want %v", tc.url, http.StatusText(got), http.StatusText(want))
+			continue
+		}
+		var got string
+		switch w.Code {
+		case http.StatusOK:
+			got = w.Body.String()
+		case http.StatusFound:
+			got = w.Header().Get("Location")
+		}
+		if got != tc.want {
+			t.Errorf("GET %q: got %q, want %q", tc.url, got, tc.want)
+		}
+	}
+
+	// An empty prefix serves "/" rather than redirecting it.
+	root := issuesapp.NewRouter(issuesApp, func(context.Context, string) (issues.RepoSpec, string, bool) {
+		return issues.RepoSpec{URI: "example.org"}, "", true
+	})
+	w := httptest.NewRecorder()
+	root.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got, want := w.Body.String(), "example.org  /"; w.Code != http.StatusOK || got != want {
+		t.Errorf("GET %q with empty prefix: got %v %q, want OK %q", "/", http.StatusText(w.Code), got, want)
+	}
+}
+
 func mockIssuesApp(repo issues.RepoSpec) (http.Handler, error) {
+	service, err := mockIssuesService(repo)
+	if err != nil {
+		return nil, err
+	}
+	return issuesapp.New(service, mockUsers{}, issuesapp.Options{}), nil
+}
+
+// mockIssuesService returns an issues service with a test issue in repo.
+func mockIssuesService(repo issues.RepoSpec) (issues.Service, error) {
 	mem := webdav.NewMemFS()
 	err := vfsutil.MkdirAll(context.Background(), mem, path.Join(repo.URI, "issues"), 0700)
 	if err != nil {
@@ -115,7 +760,7 @@ func mockIssuesApp(repo issues.RepoSpec) (http.Handler, error) {
 		return nil, err
 	}
 
-	return issuesapp.New(service, users, issuesapp.Options{}), nil
+	return service, nil
 }
 
 type mockUsers struct {
@@ -151,3 +796,131 @@ func (m mockUsers) GetAuthenticated(ctx context.Context) (users.User, error) {
 	}
 	return m.Get(ctx, userSpec)
 }
+
+// anonymousUsers is a users service where nobody is signed in.
+type anonymousUsers struct {
+	mockUsers
+}
+
+func (anonymousUsers) GetAuthenticatedSpec(context.Context) (users.UserSpec, error) {
+	return users.UserSpec{}, nil
+}
+
+func (anonymousUsers) GetAuthenticated(context.Context) (users.User, error) {
+	return users.User{}, nil
+}
+
+// mockPins is a Pinner that stores IDs of pinned issues keyed by repository URI.
+type mockPins map[string][]uint64
+
+func (m mockPins) ListPinned(_ context.Context, repo issues.RepoSpec) ([]uint64, error) {
+	return m[repo.URI], nil
+}
+
+func (m mockPins) SetPinned(_ context.Context, repo issues.RepoSpec, id uint64, pinned bool) error {
+	var ids []uint64
+	for _, i := range m[repo.URI] {
+		if i != id {
+			ids = append(ids, i)
+		}
+	}
+	if pinned {
+		ids = append(ids, id)
+	}
+	m[repo.URI] = ids
+	return nil
+}
+
+// mockLabeler is an issues service that supports bulk label actions.
+// It counts the labels removed.
+type mockLabeler struct {
+	issues.Service
+	removed int
+}
+
+func (m *mockLabeler) AddLabel(context.Context, issues.RepoSpec, uint64, string) error {
+	return nil
+}
+
+func (m *mockLabeler) RemoveLabel(context.Context, issues.RepoSpec, uint64, string) error {
+	m.removed++
+	return nil
+}
+
+// mockMilestoner is an issues service that counts milestones set.
+// If uneditable, its issues aren't editable by the current user.
+type mockMilestoner struct {
+	issues.Service
+	uneditable bool
+	set        int
+}
+
+func (m *mockMilestoner) Get(ctx context.Context, repo issues.RepoSpec, id uint64) (issues.Issue, error) {
+	issue, err := m.Service.Get(ctx, repo, id)
+	issue.Editable = issue.Editable && !m.uneditable
+	return issue, err
+}
+
+func (m *mockMilestoner) SetMilestone(context.Context, issues.RepoSpec, uint64, string) error {
+	m.set++
+	return nil
+}
+
+// mockLocks is a LockStore that stores locked conversations of a single repository.
+type mockLocks struct {
+	locked map[uint64]bool
+	events map[uint64][]issues.Event
+}
+
+func (m *mockLocks) IsLocked(_ context.Context, _ issues.RepoSpec, id uint64) (bool, error) {
+	return m.locked[id], nil
+}
+
+func (m *mockLocks) SetLocked(ctx context.Context, _ issues.RepoSpec, id uint64, locked bool) (issues.Event, error) {
+	user, err := mockUsers{}.GetAuthenticated(ctx)
+	if err != nil {
+		return issues.Event{}, err
+	}
+	m.locked[id] = locked
+	e := issues.Event{ID: uint64(1000 + len(m.events[id])), Actor: user, CreatedAt: time.Now().UTC(), Type: common.Unlocked}
+	if locked {
+		e.Type = common.Locked
+	}
+	m.events[id] = append(m.events[id], e)
+	return e, nil
+}
+
+func (m *mockLocks) ListLockEvents(_ context.Context, _ issues.RepoSpec, id uint64) ([]issues.Event, error) {
+	return m.events[id], nil
+}
+
+// mockLocker is an issues service that supports locking conversations.
+// Lock events are added to the events of issues.
+type mockLocker struct {
+	issues.Service
+	locked map[uint64]bool
+	events map[uint64][]issues.Event
+}
+
+func (m *mockLocker) IsLocked(_ context.Context, _ issues.RepoSpec, id uint64) (bool, error) {
+	return m.locked[id], nil
+}
+
+func (m *mockLocker) SetLocked(ctx context.Context, _ issues.RepoSpec, id uint64, locked bool) (issues.Event, error) {
+	user, err := mockUsers{}.GetAuthenticated(ctx)
+	if err != nil {
+		return issues.Event{}, err
+	}
+	m.locked[id] = locked
+	e := issues.Event{ID: uint64(1000 + len(m.events[id])), Actor: user, CreatedAt: time.Now().UTC(), Type: common.Unlocked}
+	if locked {
+		e.Type = common.Locked
+	}
+	m.events[id] = append(m.events[id], e)
+	return e, nil
+}
+
+func (m *mockLocker) ListEvents(ctx context.Context, repo issues.RepoSpec, id uint64, opt *issues.ListOptions) ([]issues.Event, error) {
+	es, err := m.Service.ListEvents(ctx, repo, id, opt)
+	return append(es, m.events[id]...), err
+}
@@ -0,0 +1,165 @@
+package issuesapp
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/users"
+)
+
+// markdownRenderer renders Markdown comment bodies as HTML for display
+// on a single page, including post-processing of the rendered HTML.
+type markdownRenderer struct {
+	// markdown renders a Markdown body as HTML. It can be nil,
+	// in which case GitHub Flavored Markdown is used.
+	markdown func(body string) []byte
+
+	// cache caches the output of markdown, syntax highlighted and with emoji.
+	// It can be nil. cacheConfig identifies markdown in its keys.
+	cache       *MarkdownCache
+	cacheConfig string
+
+	// resolveUser resolves a login of an @mentioned user. It can be nil,
+	// in which case @mentions are left as is.
+	resolveUser func(login string) (users.User, bool)
+
+	// resolveIssue resolves an ID of a referenced issue. It can be nil,
+	// in which case issue references are left as is.
+	resolveIssue func(id uint64) (issues.Issue, bool)
+	baseURI      string // Base URI of issue links.
+}
+
+// Render renders body as Markdown, and post-processes the result.
+func (r markdownRenderer) Render(body string) template.HTML {
+	var rendered []byte
+	switch r.cache {
+	case nil:
+		rendered = r.render(body)
+	default:
+		rendered = r.cache.render(r.cacheConfig, body, func() []byte { return r.render(body) })
+	}
+	// @mentions and issue references can only occur in bodies with '@' and '#',
+	// so skip parsing rendered HTML again for the (many) bodies without them.
+	if r.resolveUser != nil && strings.Contains(body, "@") {
+		rendered = linkMentions(rendered, r.resolveUser)
+	}
+	if r.resolveIssue != nil && strings.Contains(body, "#") {
+		rendered = linkIssueReferences(rendered, r.baseURI, r.resolveIssue)
+	}
+	return template.HTML(rendered)
+}
+
+// mayReference reports whether any comment in items may contain @mentions
+// or issue references, using the same check as Render.
+func mayReference(items []issueItem) bool {
+	for _, item := range items {
+		if c, ok := item.IssueItem.(issues.Comment); ok && strings.ContainsAny(c.Body, "@#") {
+			return true
+		}
+	}
+	return false
+}
+
+// recording returns r with resolvers that also append what they resolve to,
+// to resolved. Rendered @mentions and issue references depend on it.
+func (r markdownRenderer) recording(resolved *[]interface{}) markdownRenderer {
+	if resolveUser := r.resolveUser; resolveUser != nil {
+		r.resolveUser = func(login string) (users.User, bool) {
+			u, ok := resolveUser(login)
+			*resolved = append(*resolved, u)
+			return u, ok
+		}
+	}
+	if resolveIssue := r.resolveIssue; resolveIssue != nil {
+		r.resolveIssue = func(id uint64) (issues.Issue, bool) {
+			issue, ok := resolveIssue(id)
+			*resolved = append(*resolved, issue)
+			return issue, ok
+		}
+	}
+	return r
+}
+
+// render renders body as Markdown, with syntax highlighting and emoji.
+// Its output depends only on body and r.markdown, so it can be cached.
+func (r markdownRenderer) render(body string) []byte {
+	var rendered []byte
+	switch r.markdown {
+	case nil:
+		rendered = github_flavored_markdown.Markdown([]byte(body))
+	default:
+		rendered = r.markdown(body)
+	}
+	rendered = highlightCode(rendered)
+	return common.RenderEmojis(rendered)
+}
+
+// RenderEditable is like Render, but the task list checkboxes can be toggled.
+// It's used for comments that the current user can edit.
+func (r markdownRenderer) RenderEditable(body string) template.HTML {
+	return template.HTML(common.EnableTasks([]byte(r.Render(body)), body))
+}
+
+// markdownRenderer returns a renderer of comment bodies in repo, using Options.RenderMarkdown if set.
+// participants are the participants of the issue, if any, who can be @mentioned.
+func (h *handler) markdownRenderer(ctx context.Context, repo issues.RepoSpec, baseURI string, participants []users.User) markdownRenderer {
+	r := markdownRenderer{
+		resolveUser:  h.mentionResolver(ctx, participants),
+		resolveIssue: h.issueResolver(ctx, repo, maxIssueLookups),
+		baseURI:      baseURI,
+		cache:        h.MarkdownCache,
+		cacheConfig:  "gfm",
+	}
+	if h.RenderMarkdown != nil {
+		r.markdown = func(body string) []byte {
+			return []byte(h.RenderMarkdown(ctx, repo, body))
+		}
+		// RenderMarkdown may render bodies differently in each app and repo.
+		r.cacheConfig = fmt.Sprintf("custom:%d:%s", h.id, repo.URI)
+	}
+	return r
+}
+
+// MarkdownHandler renders a Markdown preview of the "body" form value,
+// the same way comments are rendered. Optional "issue" form value is
+// the ID of the issue being commented on, whose participants can be @mentioned.
+// It's only available to signed in users.
+func (h *handler) MarkdownHandler(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != http.MethodPost {
+		return httperror.Method{Allowed: []string{http.MethodPost}}
+	}
+	// Only signed in users can write comments, so only they need previews.
+	if err := h.checkSignedIn(req.Context()); err != nil {
+		return err
+	}
+	if err := req.ParseForm(); err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("req.ParseForm: %v", err)}
+	}
+	repoSpec := req.Context().Value(RepoSpecContextKey).(issues.RepoSpec)
+	baseURI := req.Context().Value(BaseURIContextKey).(string)
+
+	var participants []users.User
+	if issue := req.PostForm.Get("issue"); issue != "" {
+		issueID, err := strconv.ParseUint(issue, 10, 64)
+		if err != nil {
+			return httperror.BadRequest{Err: fmt.Errorf("invalid issue ID %q: %v", issue, err)}
+		}
+		participants, err = h.participants(req.Context(), repoSpec, issueID)
+		if err != nil {
+			return err
+		}
+	}
+	md := h.markdownRenderer(req.Context(), repoSpec, baseURI, participants)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := io.WriteString(w, string(md.Render(req.PostForm.Get("body"))))
+	return err
+}
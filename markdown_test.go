@@ -0,0 +1,102 @@
+package issuesapp_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issues/fs"
+	"github.com/shurcooL/issuesapp"
+	"github.com/shurcooL/webdavfs/vfsutil"
+	"golang.org/x/net/webdav"
+)
+
+func BenchmarkIssue(b *testing.B) {
+	repo := issues.RepoSpec{URI: "example.org"}
+	service, err := mockLongIssue(repo, 500)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("NoCache", func(b *testing.B) {
+		benchmarkIssue(b, repo, issuesapp.New(service, mockUsers{}, issuesapp.Options{}))
+	})
+	b.Run("Cache", func(b *testing.B) {
+		cache := issuesapp.NewMarkdownCache(1000)
+		benchmarkIssue(b, repo, issuesapp.New(service, mockUsers{}, issuesapp.Options{MarkdownCache: cache}))
+		hits, misses := cache.Stats()
+		b.ReportMetric(float64(hits)/float64(b.N), "hits/op")
+		b.ReportMetric(float64(misses)/float64(b.N), "misses/op")
+	})
+	b.Run("SmallCache", func(b *testing.B) {
+		// A cache too small for the issue, so every comment misses.
+		cache := issuesapp.NewMarkdownCache(100)
+		benchmarkIssue(b, repo, issuesapp.New(service, mockUsers{}, issuesapp.Options{MarkdownCache: cache}))
+		hits, misses := cache.Stats()
+		b.ReportMetric(float64(hits)/float64(b.N), "hits/op")
+		b.ReportMetric(float64(misses)/float64(b.N), "misses/op")
+	})
+}
+
+// benchmarkIssue benchmarks displaying issue 1 of repo.
+func benchmarkIssue(b *testing.B, repo issues.RepoSpec, issuesApp http.Handler) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("GET", "/1", nil)
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.RepoSpecContextKey, repo))
+		req = req.WithContext(context.WithValue(req.Context(), issuesapp.BaseURIContextKey, "."))
+		w := httptest.NewRecorder()
+		issuesApp.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("got %v, want %v", http.StatusText(w.Code), http.StatusText(http.StatusOK))
+		}
+	}
+}
+
+// mockLongIssue returns an issues service with a synthetic issue 1 in repo,
+// which has the given number of comments with various Markdown features.
+func mockLongIssue(repo issues.RepoSpec, comments int) (issues.Service, error) {
+	mem := webdav.NewMemFS()
+	err := vfsutil.MkdirAll(context.Background(), mem, path.Join(repo.URI, "issues"), 0700)
+	if err != nil {
+		return nil, err
+	}
+	service, err := fs.NewService(mem, nil, nil, mockUsers{})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = service.Create(context.Background(), repo, issues.Issue{
+		Title:   "Some long issue",
+		Comment: issues.Comment{Body: "This issue has many comments. :tada:"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < comments; i++ {
+		_, err = service.CreateComment(context.Background(), repo, 1, issues.Comment{
+			Body: fmt.Sprintf(commentBody, i),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return service, nil
+}
+
+const commentBody = "This is comment %d, with **bold** and _italic_ text, a [link](https://example.org) and an emoji :+1:.\n" +
+	"\n" +
+	"- [x] Done\n" +
+	"- [ ] Not done\n" +
+	"\n" +
+	"```Go\n" +
+	"func main() {\n" +
+	"\tfmt.Println(\"Hello, world!\") // A comment.\n" +
+	"}\n" +
+	"```\n" +
+	"\n" +
+	"See #1, cc @gopher.\n"
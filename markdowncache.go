@@ -0,0 +1,98 @@
+package issuesapp
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+)
+
+// MarkdownCache is a bounded LRU cache of rendered Markdown comment bodies.
+// It's safe for concurrent use, and can be shared by multiple issues apps.
+//
+// Only the output of the Markdown renderer (including syntax highlighting
+// and emoji) is cached. @mentions and issue references are linked on every
+// render, since the users and issues they resolve to may change.
+type MarkdownCache struct {
+	size int
+
+	hits, misses atomic.Uint64
+
+	mu      sync.Mutex
+	entries map[markdownCacheKey]*list.Element
+	lru     *list.List // Most recently used entries first. Values are *markdownCacheEntry.
+}
+
+// NewMarkdownCache returns a cache of up to size rendered Markdown bodies.
+func NewMarkdownCache(size int) *MarkdownCache {
+	return &MarkdownCache{
+		size:    size,
+		entries: make(map[markdownCacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *MarkdownCache) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Len returns the number of rendered bodies in the cache.
+func (c *MarkdownCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// markdownCacheKey identifies a rendered body. It's a hash of the body
+// and the configuration of the renderer that rendered it.
+type markdownCacheKey [sha256.Size]byte
+
+type markdownCacheEntry struct {
+	key      markdownCacheKey
+	rendered []byte
+}
+
+func newMarkdownCacheKey(config, body string) markdownCacheKey {
+	h := sha256.New()
+	h.Write([]byte(config))
+	h.Write([]byte{0})
+	h.Write([]byte(body))
+	var key markdownCacheKey
+	h.Sum(key[:0])
+	return key
+}
+
+// render returns the rendered body for the renderer with config, calling render
+// on a cache miss. The returned slice must not be modified.
+func (c *MarkdownCache) render(config, body string, render func() []byte) []byte {
+	key := newMarkdownCacheKey(config, body)
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return e.Value.(*markdownCacheEntry).rendered
+	}
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	// Render without holding the lock, so that concurrent renders of different
+	// bodies don't wait for each other. Concurrent misses of the same body
+	// may render it more than once, which is fine.
+	rendered := render()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return rendered
+	}
+	c.entries[key] = c.lru.PushFront(&markdownCacheEntry{key: key, rendered: rendered})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*markdownCacheEntry).key)
+	}
+	return rendered
+}
@@ -0,0 +1,86 @@
+package issuesapp
+
+import (
+	"context"
+	"html/template"
+	"testing"
+
+	"github.com/shurcooL/issues"
+)
+
+func TestMarkdownCache(t *testing.T) {
+	c := NewMarkdownCache(2)
+	var renders int
+	render := func(config, body string) string {
+		return string(c.render(config, body, func() []byte {
+			renders++
+			return []byte(config + ":" + body)
+		}))
+	}
+	check := func(wantHits, wantMisses uint64, wantRenders int) {
+		t.Helper()
+		if hits, misses := c.Stats(); hits != wantHits || misses != wantMisses {
+			t.Errorf("got %d hits and %d misses, want %d and %d", hits, misses, wantHits, wantMisses)
+		}
+		if renders != wantRenders {
+			t.Errorf("got %d renders, want %d", renders, wantRenders)
+		}
+	}
+
+	render("gfm", "a")
+	render("gfm", "b")
+	check(0, 2, 2)
+	render("gfm", "a") // Hit, and a is now the most recently used.
+	check(1, 2, 2)
+
+	// Adding c evicts b, the least recently used.
+	render("gfm", "c")
+	check(1, 3, 3)
+	if got, want := c.Len(), 2; got != want {
+		t.Errorf("got Len %d, want %d", got, want)
+	}
+	render("gfm", "a")
+	check(2, 3, 3)
+	render("gfm", "b")
+	check(2, 4, 4)
+
+	// The same body rendered by another renderer config is a different entry,
+	// so a repository's custom renderer never gets another one's HTML.
+	if got, want := render("custom:example.org/other", "b"), "custom:example.org/other:b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	check(2, 5, 5)
+	if got, want := render("gfm", "b"), "gfm:b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	check(3, 5, 5)
+}
+
+func TestMarkdownCacheSharedByApps(t *testing.T) {
+	cache := NewMarkdownCache(10)
+	newApp := func(name string) *handler {
+		return newHandler(nil, nil, Options{
+			RenderMarkdown: func(_ context.Context, _ issues.RepoSpec, body string) template.HTML {
+				return template.HTML(name + ":" + body)
+			},
+			MarkdownCache: cache,
+		})
+	}
+	a, b := newApp("a"), newApp("b")
+	repo := issues.RepoSpec{URI: "example.org"}
+	for _, tc := range []struct {
+		h    *handler
+		want template.HTML
+	}{
+		{a, "a:body"},
+		{b, "b:body"},
+		{a, "a:body"},
+	} {
+		if got := tc.h.markdownRenderer(context.Background(), repo, ".", nil).Render("body"); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+		t.Errorf("got %d hits and %d misses, want 1 and 2", hits, misses)
+	}
+}
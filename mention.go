@@ -0,0 +1,237 @@
+package issuesapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/users"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// UserSearcher is an optional interface that a users.Service can implement
+// to let users @mention others who haven't participated in an issue.
+// When a page is displayed, it's called once for each distinct @mentioned
+// login that isn't a participant, up to maxUserSearches times, so it
+// should be fast.
+type UserSearcher interface {
+	// SearchUsers returns up to limit users whose login starts with prefix,
+	// ignoring case.
+	SearchUsers(ctx context.Context, prefix string, limit int) ([]users.User, error)
+}
+
+// mentionPattern matches an @mention of a user login. The submatch is the login.
+// It's not preceded by a word character, so that email addresses aren't mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@./])@([A-Za-z0-9][A-Za-z0-9-]*)`)
+
+// Mentions returns the logins of users @mentioned in the Markdown body,
+// in order of first appearance and without duplicates.
+// Mentions inside code and links are ignored.
+func Mentions(body string) []string {
+	var logins []string
+	seen := make(map[string]bool)
+	common.RewriteText(github_flavored_markdown.Markdown([]byte(body)), func(text string) []*html.Node {
+		for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+			if login := m[1]; !seen[strings.ToLower(login)] {
+				seen[strings.ToLower(login)] = true
+				logins = append(logins, login)
+			}
+		}
+		return nil
+	})
+	return logins
+}
+
+// linkMentions returns rendered HTML with @mentions of users that resolve
+// turned into links to their profiles.
+func linkMentions(rendered []byte, resolve func(login string) (users.User, bool)) []byte {
+	return common.RewriteText(rendered, func(text string) []*html.Node {
+		matches := mentionPattern.FindAllStringSubmatchIndex(text, -1)
+		var ns []*html.Node
+		var last int
+		for _, m := range matches {
+			start, end := m[2]-1, m[3] // Include the '@' before the login.
+			user, ok := resolve(text[m[2]:m[3]])
+			if !ok {
+				continue
+			}
+			ns = append(ns, htmlg.Text(text[last:start]))
+			a := htmlg.A("@"+user.Login, user.HTMLURL)
+			a.Attr = append(a.Attr, html.Attribute{Key: atom.Class.String(), Val: "user-mention"})
+			ns = append(ns, a)
+			last = end
+		}
+		if ns == nil {
+			return nil
+		}
+		return append(ns, htmlg.Text(text[last:]))
+	})
+}
+
+// maxUserSearches is the maximum number of logins that are looked up
+// via UserSearcher by a mention resolver.
+const maxUserSearches = 10
+
+// mentionResolver returns a func that resolves logins of @mentioned users.
+// Participants of the issue are resolved first. Others are looked up
+// via the users service, if it implements UserSearcher, up to maxUserSearches
+// of them. The rest don't resolve.
+func (h *handler) mentionResolver(ctx context.Context, participants []users.User) func(login string) (users.User, bool) {
+	known := make(map[string]users.User) // Key is lowercase login.
+	for _, u := range participants {
+		known[strings.ToLower(u.Login)] = u
+	}
+	us, searchable := h.us.(UserSearcher)
+	looked := make(map[string]bool) // Set of lowercase logins looked up via UserSearcher.
+	return func(login string) (users.User, bool) {
+		key := strings.ToLower(login)
+		if u, ok := known[key]; ok {
+			return u, true
+		}
+		if !searchable || looked[key] || len(looked) == maxUserSearches {
+			return users.User{}, false
+		}
+		looked[key] = true
+		found, err := us.SearchUsers(ctx, login, mentionSuggestionLimit)
+		if err != nil {
+			log.Println("mentionResolver: SearchUsers:", err)
+			return users.User{}, false
+		}
+		for _, u := range found {
+			if strings.EqualFold(u.Login, login) {
+				known[key] = u
+				return u, true
+			}
+		}
+		return users.User{}, false
+	}
+}
+
+// notifyMentioned calls Options.Mentioned, if set, with the users
+// that are @mentioned in body of the specified comment.
+func (h *handler) notifyMentioned(ctx context.Context, repo issues.RepoSpec, issueID, commentID uint64, body string, resolve func(login string) (users.User, bool)) {
+	if h.Mentioned == nil {
+		return
+	}
+	var mentioned []users.User
+	for _, login := range Mentions(body) {
+		if u, ok := resolve(login); ok {
+			mentioned = append(mentioned, u)
+		}
+	}
+	if len(mentioned) == 0 {
+		return
+	}
+	h.Mentioned(ctx, repo, issueID, commentID, mentioned)
+}
+
+// participants returns the users who participated in the specified issue,
+// i.e., the authors of its comments (including the issue description), without duplicates.
+func (h *handler) participants(ctx context.Context, repo issues.RepoSpec, issueID uint64) ([]users.User, error) {
+	cs, err := h.is.ListComments(ctx, repo, issueID, nil)
+	if err != nil {
+		return nil, err
+	}
+	var us []users.User
+	for _, c := range cs {
+		us = appendUser(us, c.User)
+	}
+	return us, nil
+}
+
+// checkSignedIn returns a permission error if the current user isn't signed in.
+func (h *handler) checkSignedIn(ctx context.Context) error {
+	if h.us == nil {
+		return os.ErrPermission
+	}
+	user, err := h.us.GetAuthenticatedSpec(ctx)
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+// appendUser appends u to us, unless it's already there.
+func appendUser(us []users.User, u users.User) []users.User {
+	for _, v := range us {
+		if v.UserSpec == u.UserSpec {
+			return us
+		}
+	}
+	return append(us, u)
+}
+
+// MentionsHandler serves users suggested for @mention autocompletion as JSON.
+// Query parameter q is the login prefix typed so far, and optional issue is
+// the ID of the issue being commented on, whose participants are suggested first.
+// Only signed in users can list users this way.
+func (h *handler) MentionsHandler(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != http.MethodGet {
+		return httperror.Method{Allowed: []string{http.MethodGet}}
+	}
+	if err := h.checkSignedIn(req.Context()); err != nil {
+		return err
+	}
+	repoSpec := req.Context().Value(RepoSpecContextKey).(issues.RepoSpec)
+	q := req.URL.Query()
+	prefix := strings.ToLower(q.Get("q"))
+
+	var candidates []users.User
+	if issue := q.Get("issue"); issue != "" {
+		issueID, err := strconv.ParseUint(issue, 10, 64)
+		if err != nil {
+			return httperror.BadRequest{Err: fmt.Errorf("invalid issue ID %q: %v", issue, err)}
+		}
+		candidates, err = h.participants(req.Context(), repoSpec, issueID)
+		if err != nil {
+			return err
+		}
+	}
+	if us, ok := h.us.(UserSearcher); ok {
+		found, err := us.SearchUsers(req.Context(), prefix, mentionSuggestionLimit)
+		if err != nil {
+			return fmt.Errorf("SearchUsers: %v", err)
+		}
+		for _, u := range found {
+			candidates = appendUser(candidates, u)
+		}
+	}
+
+	suggestions := []mentionSuggestion{} // Encode as [] rather than null when there are none.
+	for _, u := range candidates {
+		if len(suggestions) == mentionSuggestionLimit {
+			break
+		}
+		if u.Login == "" || !strings.HasPrefix(strings.ToLower(u.Login), prefix) {
+			continue
+		}
+		suggestions = append(suggestions, mentionSuggestion{Login: u.Login, Name: u.Name, AvatarURL: u.AvatarURL})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(suggestions)
+}
+
+// mentionSuggestionLimit is the maximum number of users suggested for @mention autocompletion.
+const mentionSuggestionLimit = 10
+
+// mentionSuggestion is a user suggested for @mention autocompletion.
+type mentionSuggestion struct {
+	Login     string
+	Name      string
+	AvatarURL string
+}
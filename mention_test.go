@@ -0,0 +1,83 @@
+package issuesapp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/shurcooL/users"
+)
+
+func TestMentionPattern(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"@alice", []string{"alice"}},
+		{"Hi @alice and @bob-2.", []string{"alice", "bob-2"}},
+		{"(@alice)", []string{"alice"}},
+		{"gopher@example.org", nil},
+		{"@@alice", nil},
+		{"./@alice", nil},
+		{"@-alice", nil},
+	}
+	for _, tc := range tests {
+		var got []string
+		for _, m := range mentionPattern.FindAllStringSubmatch(tc.in, -1) {
+			got = append(got, m[1])
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("mentionPattern in %q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Hi @alice, @bob and @Alice.", []string{"alice", "bob"}},
+		{"Code `@alice` isn't a mention, @bob is.", []string{"bob"}},
+		{"```\n@alice\n```", nil},
+		{"A [link to @alice](https://example.org/alice).", nil},
+		{"Mail gopher@example.org.", nil},
+		{"Double @@alice.", nil},
+	}
+	for _, tc := range tests {
+		if got := Mentions(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Mentions(%q): got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestLinkMentions(t *testing.T) {
+	alice := users.User{Login: "alice", HTMLURL: "https://example.org/alice"}
+	resolve := func(login string) (users.User, bool) {
+		if strings.EqualFold(login, alice.Login) {
+			return alice, true
+		}
+		return users.User{}, false
+	}
+	const link = `<a href="https://example.org/alice" class="user-mention">@alice</a>`
+
+	tests := []struct {
+		in       string
+		wantLink bool
+	}{
+		{"Hi @alice.", true},
+		{"Hi @Alice.", true},
+		{"Hi @bob.", false},
+		{"Code `@alice`.", false},
+		{"A [link to @alice](https://example.org/).", false},
+		{"Mail alice@example.org.", false},
+		{"Double @@alice.", false},
+	}
+	for _, tc := range tests {
+		got := string(linkMentions(github_flavored_markdown.Markdown([]byte(tc.in)), resolve))
+		if strings.Contains(got, link) != tc.wantLink {
+			t.Errorf("linkMentions of %q: got %q, want link %v", tc.in, got, tc.wantLink)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package issuesapp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/issues"
+)
+
+// Pinner stores which issues of repositories are pinned. Pinned issues are
+// displayed above the list of issues, regardless of the current filter.
+//
+// It's an optional interface that an issues.Service can implement.
+// Otherwise, a host-provided implementation can be set in Options.Pins.
+type Pinner interface {
+	// ListPinned lists the IDs of pinned issues of repo, in the order they're displayed.
+	ListPinned(ctx context.Context, repo issues.RepoSpec) ([]uint64, error)
+
+	// SetPinned pins or unpins issue id of repo.
+	SetPinned(ctx context.Context, repo issues.RepoSpec, id uint64, pinned bool) error
+}
+
+// DefaultMaxPinned is the maximum number of pinned issues per repository,
+// unless Options.MaxPinned is set.
+const DefaultMaxPinned = 3
+
+// pinner returns the Pinner of the app, or nil if issues can't be pinned.
+func (h *handler) pinner() Pinner {
+	if p, ok := h.is.(Pinner); ok {
+		return p
+	}
+	return h.Pins
+}
+
+// maxPinned returns the maximum number of pinned issues per repository.
+func (h *handler) maxPinned() int {
+	if h.MaxPinned == 0 {
+		return DefaultMaxPinned
+	}
+	return h.MaxPinned
+}
+
+// listPinned returns the IDs of pinned issues of repo,
+// or nil if issues can't be pinned.
+func (h *handler) listPinned(ctx context.Context, repo issues.RepoSpec) ([]uint64, error) {
+	p := h.pinner()
+	if p == nil {
+		return nil, nil
+	}
+	ids, err := p.ListPinned(ctx, repo)
+	if err != nil {
+		return nil, fmt.Errorf("ListPinned: %v", err)
+	}
+	return ids, nil
+}
+
+// pinnedIssues returns the pinned issues of repo.
+// Pinned issues that no longer exist are skipped.
+func (h *handler) pinnedIssues(ctx context.Context, repo issues.RepoSpec) ([]issues.Issue, error) {
+	ids, err := h.listPinned(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var is []issues.Issue
+	for _, id := range ids {
+		i, err := h.is.Get(ctx, repo, id)
+		if os.IsNotExist(err) {
+			log.Printf("pinnedIssues: pinned issue %d of %q doesn't exist: %v\n", id, repo.URI, err)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		is = append(is, i)
+	}
+	return is, nil
+}
+
+// PostPinHandler pins or unpins issue issueID, as specified
+// by the "pinned" form value, and redirects back to the issue.
+func (h *handler) PostPinHandler(w http.ResponseWriter, req *http.Request, issueID uint64) error {
+	if req.Method != http.MethodPost {
+		return httperror.Method{Allowed: []string{http.MethodPost}}
+	}
+	if err := req.ParseForm(); err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("req.ParseForm: %v", err)}
+	}
+	pinned, err := strconv.ParseBool(req.PostForm.Get("pinned"))
+	if err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("parsing pinned form value: %v", err)}
+	}
+	p := h.pinner()
+	if p == nil {
+		return httperror.HTTP{Code: http.StatusNotFound, Err: fmt.Errorf("issues can't be pinned")}
+	}
+	state, err := h.state(req, issueID)
+	if err != nil {
+		return err
+	}
+	state.Issue, err = h.is.Get(req.Context(), state.RepoSpec, issueID)
+	if err != nil {
+		return err
+	}
+	if !h.policy().CanPin(req.Context(), state.RepoSpec, state.Issue, state.CurrentUser) {
+		return os.ErrPermission
+	}
+	if pinned {
+		ids, err := p.ListPinned(req.Context(), state.RepoSpec)
+		if err != nil {
+			return fmt.Errorf("ListPinned: %v", err)
+		}
+		if !containsID(ids, issueID) && len(ids) >= h.maxPinned() {
+			return httperror.HTTP{Code: http.StatusConflict, Err: fmt.Errorf("can't pin more than %d issues", h.maxPinned())}
+		}
+	}
+	err = p.SetPinned(req.Context(), state.RepoSpec, issueID, pinned)
+	if err != nil {
+		return err
+	}
+	return httperror.Redirect{URL: fmt.Sprintf("%s/%d", state.BaseURI, issueID)}
+}
+
+func containsID(ids []uint64, id uint64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
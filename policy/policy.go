@@ -0,0 +1,87 @@
+// Package policy decides what users can do in issue trackers of repositories.
+package policy
+
+import (
+	"context"
+
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/users"
+)
+
+// Policy decides what user can do in repo. It's consulted before rendering
+// controls and before performing changes. The issues service can still reject
+// changes that a policy allows. user is the zero value if not signed in.
+type Policy interface {
+	// CanCreateIssue reports whether user can create issues.
+	CanCreateIssue(ctx context.Context, repo issues.RepoSpec, user users.User) bool
+
+	// CanComment reports whether user can comment on issue.
+	CanComment(ctx context.Context, repo issues.RepoSpec, issue issues.Issue, user users.User) bool
+
+	// CanChangeState reports whether user can close or reopen issue,
+	// and rename it.
+	CanChangeState(ctx context.Context, repo issues.RepoSpec, issue issues.Issue, user users.User) bool
+
+	// CanEditComment reports whether user can edit comment of issue.
+	// A comment with ID 0 is the issue description.
+	CanEditComment(ctx context.Context, repo issues.RepoSpec, issue issues.Issue, comment issues.Comment, user users.User) bool
+
+	// CanReact reports whether user can react to comments of issue.
+	CanReact(ctx context.Context, repo issues.RepoSpec, issue issues.Issue, user users.User) bool
+
+	// CanLock reports whether user can lock and unlock the conversation of issue.
+	// While it's locked, only users who can lock it can comment and react.
+	CanLock(ctx context.Context, repo issues.RepoSpec, issue issues.Issue, user users.User) bool
+
+	// CanPin reports whether user can pin and unpin issue.
+	CanPin(ctx context.Context, repo issues.RepoSpec, issue issues.Issue, user users.User) bool
+
+	// CanApplyLabel reports whether user can apply the label with name to issues,
+	// when creating them or as a bulk action, and remove it as a bulk action.
+	CanApplyLabel(ctx context.Context, repo issues.RepoSpec, label string, user users.User) bool
+
+	// CanSetMilestone reports whether user can set the milestone of issue,
+	// as a bulk action.
+	CanSetMilestone(ctx context.Context, repo issues.RepoSpec, issue issues.Issue, user users.User) bool
+}
+
+// SignedIn is a policy that lets signed in users do everything,
+// leaving it up to the issues service to reject changes.
+// It's the policy of the issues app if none is set.
+type SignedIn struct{}
+
+func (SignedIn) CanCreateIssue(_ context.Context, _ issues.RepoSpec, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanComment(_ context.Context, _ issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanChangeState(_ context.Context, _ issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanEditComment(_ context.Context, _ issues.RepoSpec, _ issues.Issue, _ issues.Comment, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanReact(_ context.Context, _ issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanLock(_ context.Context, _ issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanPin(_ context.Context, _ issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanApplyLabel(_ context.Context, _ issues.RepoSpec, _ string, user users.User) bool {
+	return user.ID != 0
+}
+
+func (SignedIn) CanSetMilestone(_ context.Context, _ issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return user.ID != 0
+}
@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/users"
+)
+
+// Role is a role of a user in a repository.
+type Role int
+
+const (
+	// None is the role of users who can only read issues,
+	// such as users who aren't signed in.
+	None Role = iota
+
+	// Reader can create issues, comment and react. They can edit
+	// their own comments, and close and reopen their own issues.
+	// They can only apply labels that are allowed in the repository.
+	Reader
+
+	// Triager can also close, reopen and rename any issue,
+	// and set milestones.
+	Triager
+
+	// Maintainer can also edit any comment, lock conversations, pin issues
+	// and apply any label.
+	// They can still comment and react in locked conversations.
+	Maintainer
+)
+
+func (r Role) String() string {
+	switch r {
+	case None:
+		return "none"
+	case Reader:
+		return "reader"
+	case Triager:
+		return "triager"
+	case Maintainer:
+		return "maintainer"
+	default:
+		return "unknown role"
+	}
+}
+
+// Roles is a policy based on roles of users in repositories.
+// Site admins are maintainers of all repositories.
+type Roles struct {
+	// Default is the role of signed in users in repositories
+	// where they aren't given one in Repos.
+	Default Role
+
+	// Repos are roles of users in repositories, keyed by repository URI.
+	Repos map[string]map[users.UserSpec]Role
+
+	// AllowedLabels are names of labels that users who aren't maintainers
+	// can apply in repositories, keyed by repository URI.
+	AllowedLabels map[string][]string
+}
+
+// Role returns the role of user in repo.
+func (p Roles) Role(repo issues.RepoSpec, user users.User) Role {
+	switch {
+	case user.ID == 0:
+		return None
+	case user.SiteAdmin:
+		return Maintainer
+	}
+	if role, ok := p.Repos[repo.URI][user.UserSpec]; ok {
+		return role
+	}
+	return p.Default
+}
+
+func (p Roles) CanCreateIssue(_ context.Context, repo issues.RepoSpec, user users.User) bool {
+	return p.Role(repo, user) >= Reader
+}
+
+func (p Roles) CanComment(_ context.Context, repo issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return p.Role(repo, user) >= Reader
+}
+
+func (p Roles) CanChangeState(_ context.Context, repo issues.RepoSpec, issue issues.Issue, user users.User) bool {
+	switch role := p.Role(repo, user); {
+	case role >= Triager:
+		return true
+	case role >= Reader:
+		return issue.User.UserSpec == user.UserSpec
+	default:
+		return false
+	}
+}
+
+func (p Roles) CanEditComment(_ context.Context, repo issues.RepoSpec, _ issues.Issue, comment issues.Comment, user users.User) bool {
+	switch role := p.Role(repo, user); {
+	case role >= Maintainer:
+		return true
+	case role >= Reader:
+		return comment.User.UserSpec == user.UserSpec
+	default:
+		return false
+	}
+}
+
+func (p Roles) CanReact(_ context.Context, repo issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return p.Role(repo, user) >= Reader
+}
+
+func (p Roles) CanLock(_ context.Context, repo issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return p.Role(repo, user) >= Maintainer
+}
+
+func (p Roles) CanPin(_ context.Context, repo issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return p.Role(repo, user) >= Maintainer
+}
+
+func (p Roles) CanApplyLabel(_ context.Context, repo issues.RepoSpec, label string, user users.User) bool {
+	switch role := p.Role(repo, user); {
+	case role >= Maintainer:
+		return true
+	case role >= Reader:
+		for _, l := range p.AllowedLabels[repo.URI] {
+			if l == label {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func (p Roles) CanSetMilestone(_ context.Context, repo issues.RepoSpec, _ issues.Issue, user users.User) bool {
+	return p.Role(repo, user) >= Triager
+}
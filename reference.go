@@ -0,0 +1,207 @@
+package issuesapp
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/shurcooL/issues"
+	"github.com/shurcooL/issuesapp/common"
+	"github.com/shurcooL/issuesapp/component"
+	"github.com/shurcooL/users"
+	"golang.org/x/net/html"
+)
+
+// CrossReferencer is an optional interface that an issues.Service can implement
+// to record when an issue is mentioned in another issue of the same repository.
+// Recorded cross-references are displayed in the timeline of the mentioned issue.
+type CrossReferencer interface {
+	// CrossReference records that issue id was mentioned as described by ref.
+	CrossReference(ctx context.Context, repo issues.RepoSpec, id uint64, ref CrossReference) error
+
+	// ListCrossReferences lists the recorded mentions of issue id.
+	ListCrossReferences(ctx context.Context, repo issues.RepoSpec, id uint64) ([]CrossReference, error)
+}
+
+// CrossReference is a mention of an issue in another issue.
+type CrossReference struct {
+	Actor     users.User
+	CreatedAt time.Time
+	IssueID   uint64 // IssueID is the ID of the issue that mentioned the referenced issue.
+	CommentID uint64 // CommentID is the ID of the comment that mentioned the referenced issue.
+}
+
+// issueReferencePattern matches a reference to an issue, like "#123". The submatch is the issue ID.
+var issueReferencePattern = regexp.MustCompile(`(?:^|[^\w#/&])#([1-9][0-9]*)\b`)
+
+// issueReferences returns the IDs of issues referenced in the Markdown body,
+// in order of first appearance and without duplicates.
+// References inside code and links are ignored.
+func issueReferences(body string) []uint64 {
+	var ids []uint64
+	seen := make(map[uint64]bool)
+	common.RewriteText(github_flavored_markdown.Markdown([]byte(body)), func(text string) []*html.Node {
+		for _, m := range issueReferencePattern.FindAllStringSubmatch(text, -1) {
+			id, err := strconv.ParseUint(m[1], 10, 64)
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids
+}
+
+// linkIssueReferences returns rendered HTML with references to issues
+// that resolve turned into links to them.
+func linkIssueReferences(rendered []byte, baseURI string, resolve func(id uint64) (issues.Issue, bool)) []byte {
+	return common.RewriteText(rendered, func(text string) []*html.Node {
+		var ns []*html.Node
+		var last int
+		for _, m := range issueReferencePattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2]-1, m[3] // Include the '#' before the issue ID.
+			id, err := strconv.ParseUint(text[m[2]:m[3]], 10, 64)
+			if err != nil {
+				continue
+			}
+			issue, ok := resolve(id)
+			if !ok {
+				continue
+			}
+			ns = append(ns, &html.Node{Type: html.TextNode, Data: text[last:start]})
+			ns = append(ns, component.IssueReference{Issue: issue, BaseURI: baseURI}.Render()...)
+			last = end
+		}
+		if ns == nil {
+			return nil
+		}
+		return append(ns, &html.Node{Type: html.TextNode, Data: text[last:]})
+	})
+}
+
+// maxIssueLookups is the maximum number of distinct issues that are looked up
+// one by one to render the references in the comment bodies of a page.
+// References beyond it are left as plain text.
+const maxIssueLookups = 20
+
+// issueResolver returns a func that resolves IDs of referenced issues in repo.
+// Issues that can't be fetched, e.g., because they don't exist, don't resolve.
+// If limit is positive, only the first limit distinct IDs are looked up, and the rest don't resolve.
+func (h *handler) issueResolver(ctx context.Context, repo issues.RepoSpec, limit int) func(id uint64) (issues.Issue, bool) {
+	resolved := make(map[uint64]*issues.Issue) // A nil value means it was looked up but doesn't resolve.
+	return func(id uint64) (issues.Issue, bool) {
+		if issue, ok := resolved[id]; ok {
+			return derefIssue(issue)
+		}
+		if limit > 0 && len(resolved) == limit {
+			return issues.Issue{}, false
+		}
+		issue, err := h.is.Get(ctx, repo, id)
+		if err != nil {
+			resolved[id] = nil
+			return issues.Issue{}, false
+		}
+		resolved[id] = &issue
+		return issue, true
+	}
+}
+
+func derefIssue(issue *issues.Issue) (issues.Issue, bool) {
+	if issue == nil {
+		return issues.Issue{}, false
+	}
+	return *issue, true
+}
+
+// crossReference records cross-references to the issues referenced in body
+// of the specified comment, if the issues service implements CrossReferencer.
+// Errors are logged rather than returned, since the comment has already been posted.
+func (h *handler) crossReference(ctx context.Context, repo issues.RepoSpec, issueID uint64, comment issues.Comment, resolve func(id uint64) (issues.Issue, bool)) {
+	cr, ok := h.is.(CrossReferencer)
+	if !ok {
+		return
+	}
+	for _, id := range issueReferences(comment.Body) {
+		if id == issueID {
+			continue
+		}
+		if _, ok := resolve(id); !ok {
+			continue
+		}
+		err := cr.CrossReference(ctx, repo, id, CrossReference{
+			Actor:     comment.User,
+			CreatedAt: comment.CreatedAt,
+			IssueID:   issueID,
+			CommentID: comment.ID,
+		})
+		if err != nil {
+			log.Printf("crossReference: CrossReference(%d): %v\n", id, err)
+		}
+	}
+}
+
+// crossReferenceIDBase is the first ID of cross-reference items. Cross-references
+// don't have IDs of their own, so they're numbered from it to keep them apart
+// from the comments and events they're sorted with in a timeline.
+const crossReferenceIDBase = 1 << 40
+
+// crossReferenceItems returns the cross-references to the specified issue,
+// for display in its timeline. The mentioning issues are fetched to display
+// their current title and state. If there are more than maxIssueLookups of them,
+// they're fetched with a single issues.Service.List call instead of one Get each.
+// Cross-references from issues that aren't visible are left out.
+func (h *handler) crossReferenceItems(ctx context.Context, repo issues.RepoSpec, issueID uint64) ([]issueItem, error) {
+	cr, ok := h.is.(CrossReferencer)
+	if !ok {
+		return nil, nil
+	}
+	refs, err := cr.ListCrossReferences(ctx, repo, issueID)
+	if err != nil {
+		return nil, err
+	}
+	sources := make(map[uint64]bool)
+	for _, ref := range refs {
+		sources[ref.IssueID] = true
+	}
+	resolve := h.issueResolver(ctx, repo, 0)
+	if len(sources) > maxIssueLookups {
+		is, err := h.is.List(ctx, repo, issues.IssueListOptions{State: issues.AllStates})
+		if err != nil {
+			return nil, err
+		}
+		listed := make(map[uint64]issues.Issue, len(is))
+		for _, issue := range is {
+			listed[issue.ID] = issue
+		}
+		resolve = func(id uint64) (issues.Issue, bool) {
+			issue, ok := listed[id]
+			return issue, ok
+		}
+	}
+	var items []issueItem
+	for i, ref := range refs {
+		source, ok := resolve(ref.IssueID)
+		if !ok {
+			// The mentioning issue is no longer visible.
+			continue
+		}
+		items = append(items, issueItem{crossReferenceItem{
+			ID:             crossReferenceIDBase + uint64(i),
+			CrossReference: ref,
+			Source:         source,
+		}})
+	}
+	return items, nil
+}
+
+// crossReferenceItem is a cross-reference to an issue, for display purposes.
+type crossReferenceItem struct {
+	ID uint64 // ID orders cross-references that were made at the same time. See crossReferenceIDBase.
+	CrossReference
+	Source issues.Issue // Source is the issue that mentioned the referenced issue.
+}
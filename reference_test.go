@@ -0,0 +1,123 @@
+package issuesapp
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/shurcooL/issues"
+)
+
+func TestIssueReferences(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []uint64
+	}{
+		{"Fixes #1 and #23, see #1.", []uint64{1, 23}},
+		{"(#1)", []uint64{1}},
+		{"Code `#1` and\n\n    #2\n\nisn't a reference.", nil},
+		{"A [link to #1](https://example.org/1).", nil},
+		{"#0, #01 and #007 aren't references.", nil},
+		{"a#1, #1a, ##1 and /#1 aren't references.", nil},
+		// Escaped references render as "#1", so they're references too.
+		{"&#35;1", []uint64{1}},
+	}
+	for _, tc := range tests {
+		if got := issueReferences(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("issueReferences(%q): got %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestIssueResolverLimit(t *testing.T) {
+	s := &countingGets{}
+	h := &handler{is: s}
+	resolve := h.issueResolver(context.Background(), issues.RepoSpec{URI: "example.org"}, maxIssueLookups)
+	for id := uint64(1); id <= 2*maxIssueLookups; id++ {
+		resolve(id)
+		resolve(id) // Repeated lookups are cached.
+	}
+	if s.gets != maxIssueLookups {
+		t.Errorf("got %d calls to Get, want %d", s.gets, maxIssueLookups)
+	}
+
+	s.gets = 0
+	resolve = h.issueResolver(context.Background(), issues.RepoSpec{URI: "example.org"}, 0)
+	for id := uint64(1); id <= 2*maxIssueLookups; id++ {
+		resolve(id)
+	}
+	if s.gets != 2*maxIssueLookups {
+		t.Errorf("without a limit: got %d calls to Get, want %d", s.gets, 2*maxIssueLookups)
+	}
+}
+
+func TestCrossReferenceItemsBatch(t *testing.T) {
+	for _, n := range []int{maxIssueLookups, maxIssueLookups + 1} {
+		s := &crossReferencing{}
+		for id := uint64(1); id <= uint64(n); id++ {
+			s.refs = append(s.refs, CrossReference{IssueID: id + 1, CommentID: 1})
+		}
+		h := &handler{is: s}
+		items, err := h.crossReferenceItems(context.Background(), issues.RepoSpec{URI: "example.org"}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n <= maxIssueLookups {
+			if s.gets != n || s.lists != 0 {
+				t.Errorf("%d cross-references: got %d calls to Get and %d to List, want %d and 0", n, s.gets, s.lists, n)
+			}
+			continue
+		}
+		if s.gets != 0 || s.lists != 1 {
+			t.Errorf("%d cross-references: got %d calls to Get and %d to List, want 0 and 1", n, s.gets, s.lists)
+		}
+		if len(items) != n {
+			t.Fatalf("%d cross-references: got %d items", n, len(items))
+		}
+		seen := make(map[uint64]bool)
+		for _, item := range items {
+			// The cross-references share a CommentID, but their IDs must be unique.
+			if id := item.ID(); id < crossReferenceIDBase || seen[id] {
+				t.Errorf("%d cross-references: got item ID %d, want a unique one from crossReferenceIDBase", n, id)
+			}
+			seen[item.ID()] = true
+		}
+	}
+}
+
+// countingGets is an issues service that counts calls to Get.
+type countingGets struct {
+	issues.Service
+	gets int
+}
+
+func (s *countingGets) Get(context.Context, issues.RepoSpec, uint64) (issues.Issue, error) {
+	s.gets++
+	return issues.Issue{}, os.ErrNotExist
+}
+
+// crossReferencing is an issues service with cross-references to issue 1
+// that counts calls to Get and List.
+type crossReferencing struct {
+	countingGets
+	refs  []CrossReference
+	lists int
+}
+
+func (s *crossReferencing) List(context.Context, issues.RepoSpec, issues.IssueListOptions) ([]issues.Issue, error) {
+	s.lists++
+	var is []issues.Issue
+	for _, ref := range s.refs {
+		is = append(is, issues.Issue{ID: ref.IssueID})
+	}
+	return is, nil
+}
+
+func (*crossReferencing) CrossReference(context.Context, issues.RepoSpec, uint64, CrossReference) error {
+	return nil
+}
+
+func (s *crossReferencing) ListCrossReferences(context.Context, issues.RepoSpec, uint64) ([]CrossReference, error) {
+	return s.refs, nil
+}
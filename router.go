@@ -0,0 +1,104 @@
+package issuesapp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/shurcooL/issues"
+)
+
+// RepoResolver resolves the repository whose issues are served at the beginning
+// of URL path. It returns the repository and its prefix of path, which is its
+// base URI. It reports false if path doesn't begin with a prefix of a known repository.
+type RepoResolver func(ctx context.Context, path string) (repo issues.RepoSpec, prefix string, ok bool)
+
+// PatternResolver returns a RepoResolver for repositories whose issues are served
+// at prefixes that match pattern. The pattern is a path of literal and variable
+// segments, such as "/{host}/{owner}/{repo}/issues" or "/github.com/{owner}/{repo}/issues".
+// Variable segments match any non-empty path segment.
+//
+// The URI of a repository is its prefix up to and including the last variable segment,
+// without the leading slash. For example, both patterns above resolve "/github.com/shurcooL/issuesapp/issues/1"
+// to repository "github.com/shurcooL/issuesapp", with prefix "/github.com/shurcooL/issuesapp/issues".
+//
+// known reports whether repo is a known repository. It can be nil, in which case
+// all repositories that match pattern are known.
+func PatternResolver(pattern string, known func(ctx context.Context, repo issues.RepoSpec) bool) RepoResolver {
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	uriLen := 0 // Number of segments in the repository URI.
+	for i, s := range segments {
+		if isVariable(s) {
+			uriLen = i + 1
+		}
+	}
+	return func(ctx context.Context, path string) (issues.RepoSpec, string, bool) {
+		elems := strings.SplitN(strings.TrimPrefix(path, "/"), "/", len(segments)+1)
+		if len(elems) < len(segments) {
+			return issues.RepoSpec{}, "", false
+		}
+		for i, s := range segments {
+			switch isVariable(s) {
+			case true:
+				if elems[i] == "" {
+					return issues.RepoSpec{}, "", false
+				}
+			case false:
+				if elems[i] != s {
+					return issues.RepoSpec{}, "", false
+				}
+			}
+		}
+		repo := issues.RepoSpec{URI: strings.Join(elems[:uriLen], "/")}
+		if known != nil && !known(ctx, repo) {
+			return issues.RepoSpec{}, "", false
+		}
+		return repo, "/" + strings.Join(elems[:len(segments)], "/"), true
+	}
+}
+
+// isVariable reports whether pattern segment s is a variable, like "{owner}".
+func isVariable(s string) bool {
+	return strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}")
+}
+
+// NewRouter returns an http.Handler that serves issuesApp, an issues app created with New,
+// for the repositories that resolve resolves. It sets RepoSpecContextKey and BaseURIContextKey
+// context keys of each request, and strips the prefix of the repository from its URL path.
+// A dashboard created with NewDashboard can be served the same way, with a resolver
+// that returns its base URI as the prefix.
+//
+// Requests for a non-empty prefix with a trailing slash are redirected to the prefix, and
+// requests for unknown repositories get 404 Not Found. For example, the issues of repositories
+// hosted on github.com can be served with:
+//
+// 	router := issuesapp.NewRouter(issuesApp, issuesapp.PatternResolver("/github.com/{owner}/{repo}/issues", nil))
+// 	http.Handle("/github.com/", router)
+func NewRouter(issuesApp http.Handler, resolve RepoResolver) http.Handler {
+	return &router{app: issuesApp, resolve: resolve}
+}
+
+type router struct {
+	app     http.Handler
+	resolve RepoResolver
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	repo, prefix, ok := r.resolve(req.Context(), req.URL.Path)
+	if !ok || (req.URL.Path != prefix && !strings.HasPrefix(req.URL.Path, prefix+"/")) {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	if req.URL.Path == prefix+"/" && prefix != "" {
+		baseURL := prefix
+		if req.URL.RawQuery != "" {
+			baseURL += "?" + req.URL.RawQuery
+		}
+		http.Redirect(w, req, baseURL, http.StatusFound)
+		return
+	}
+	req = stripPrefix(req, len(prefix))
+	req = req.WithContext(context.WithValue(req.Context(), RepoSpecContextKey, repo))
+	req = req.WithContext(context.WithValue(req.Context(), BaseURIContextKey, prefix))
+	r.app.ServeHTTP(w, req)
+}
@@ -0,0 +1,49 @@
+package issuesapp
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/shurcooL/issues"
+)
+
+// maxBodyLookups is the maximum number of issue bodies that fillBodies fetches
+// per list of issues, so that a long list doesn't turn into as many
+// issues.Service.ListComments calls.
+const maxBodyLookups = 20
+
+// fillBodies sets the body of issues in is that have an empty body,
+// so that task progress can be displayed for them. It's for issues services
+// that don't include bodies in issues.Service.List, see Options.FetchBodies.
+// The bodies of at most maxBodyLookups issues are fetched, concurrently.
+// Errors are logged and leave the body empty.
+func fillBodies(ctx context.Context, service issues.Service, repo issues.RepoSpec, is []issues.Issue) {
+	var (
+		wg      sync.WaitGroup
+		lookups int
+	)
+	for i := range is {
+		if is[i].Body != "" {
+			continue
+		}
+		if lookups == maxBodyLookups {
+			break
+		}
+		lookups++
+		wg.Add(1)
+		go func(issue *issues.Issue) {
+			defer wg.Done()
+			// The first comment of an issue is its description.
+			cs, err := service.ListComments(ctx, repo, issue.ID, &issues.ListOptions{Start: 0, Length: 1})
+			if err != nil {
+				log.Printf("fillBodies: ListComments(%d): %v\n", issue.ID, err)
+				return
+			}
+			if len(cs) > 0 {
+				issue.Body = cs[0].Body
+			}
+		}(&is[i])
+	}
+	wg.Wait()
+}